@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errUnauthorized  = &APIError{http.StatusUnauthorized, "unauthorized", "admin token required"}
+	errReasonMissing = &APIError{http.StatusBadRequest, "reason_required", "a reason is required"}
+	errUnknownTeam   = &APIError{http.StatusNotFound, "unknown_team", "unknown team"}
+	errNotBanned     = &APIError{http.StatusNotFound, "not_banned", "team is not banned"}
+)
+
+// BanList holds the teams that may not answer, with the reason.
+type BanList struct {
+	Teams map[string]string
+	mu    *sync.Mutex
+}
+
+func NewBanList() *BanList {
+	return &BanList{
+		Teams: make(map[string]string),
+		mu:    &sync.Mutex{},
+	}
+}
+
+// NewBanListFromFile is NewBanList with the bans saved to path, if there
+// are any.
+func NewBanListFromFile(path string) (*BanList, error) {
+	bl := NewBanList()
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return bl, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, bl); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if bl.Teams == nil {
+		bl.Teams = make(map[string]string)
+	}
+	return bl, nil
+}
+
+func (bl *BanList) Ban(team, reason string) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bl.Teams[team] = reason
+	return bl.save("bans_backup.json")
+}
+
+// Unban lifts the ban of team, ok is false when it was not banned.
+func (bl *BanList) Unban(team string) (ok bool, err error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if _, ok := bl.Teams[team]; !ok {
+		return false, nil
+	}
+	delete(bl.Teams, team)
+	return true, bl.save("bans_backup.json")
+}
+
+func (bl *BanList) save(path string) error {
+	buf, err := json.Marshal(bl)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf)
+}
+
+func (bl *BanList) Banned(team string) bool {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	_, ok := bl.Teams[team]
+	return ok
+}
+
+func (bl *BanList) List() map[string]string {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	m := make(map[string]string, len(bl.Teams))
+	for k, v := range bl.Teams {
+		m[k] = v
+	}
+	return m
+}
+
+// AdminRequest is the body of admin actions. Number is 1-based like in
+// /answer/:number.
+type AdminRequest struct {
+	Number int    `json:"number"`
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+type AdminState struct {
+	Paused    bool                 `json:"paused"`
+	Override  map[int]string       `json:"override"`
+	Questions []QuestionInfo       `json:"questions"`
+	Rounds    []RoundInfo          `json:"rounds"`
+	Ranking   []RankingItem        `json:"ranking"`
+	Teams     map[string]string    `json:"teams"`
+	Bans      map[string]string    `json:"bans"`
+	Limiter   map[string]time.Time `json:"limiter"`
+	Flags     []IssuedFlag         `json:"flags"`
+	Audit     []AuditEntry         `json:"audit"`
+}
+
+// adminAuth lets through requests bearing the admin token of the config.
+// Without a token the admin routes are closed.
+func adminAuth(c *gin.Context) {
+	if !adminAuthorized(c) {
+		c.AbortWithStatusJSON(errUnauthorized.Status, ErrorResponse{Error: errUnauthorized})
+		return
+	}
+	c.Next()
+}
+
+// adminAuthorized checks the admin token. Pages of the dashboard may pass
+// it as the admin_token cookie, actions must send the header. Nobody is
+// authorized when the configured token is weak.
+func adminAuthorized(c *gin.Context) bool {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" && c.Request.Method == http.MethodGet {
+		token, _ = c.Cookie("admin_token")
+	}
+	stateMu.RLock()
+	want := config.Admin.Token
+	stateMu.RUnlock()
+	return !weakToken(want) && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// adminRequest reads the optional body of an admin action.
+func adminRequest(c *gin.Context) AdminRequest {
+	var req AdminRequest
+	if c.Request.ContentLength != 0 {
+		c.ShouldBindJSON(&req)
+	}
+	return req
+}
+
+func adminError(c *gin.Context, err *APIError) {
+	c.JSON(err.Status, ErrorResponse{Error: err})
+}
+
+func adminDone(c *gin.Context, action, target, reason string) {
+	audit.Record(getIpAddr(c.Request), action, target, reason, "")
+	requestLogger(c.Request).Info("admin", "action", action, "target", target, "reason", reason)
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": action + " done",
+	})
+}
+
+func adminReload(c *gin.Context) {
+	req := adminRequest(c)
+	if err := reloadConfig(*pathConfig); err != nil {
+		adminError(c, &APIError{http.StatusConflict, "reload_rejected", err.Error()})
+		return
+	}
+	adminDone(c, "reload", *pathConfig, req.Reason)
+}
+
+func adminState(c *gin.Context) {
+	override, paused := game.Overrides()
+	c.JSON(http.StatusOK, AdminState{
+		Paused:    paused,
+		Override:  override,
+		Questions: game.Info(clock.Now()),
+		Rounds:    roundsWithRanking(game.Rounds(clock.Now())),
+		Ranking:   ranking.Snapshot(),
+		Teams:     teams,
+		Bans:      bans.List(),
+		Limiter:   iBreaker.Snapshot(),
+		Flags:     issuer.List(),
+		Audit:     audit.List(),
+	})
+}
+
+// adminQuestion opens, closes or pauses a question, or returns it to its
+// schedule.
+func adminQuestion(c *gin.Context) {
+	req := adminRequest(c)
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		adminError(c, errUnknownQuestion)
+		return
+	}
+	state, ok := map[string]string{
+		"open":     "open",
+		"close":    "closed",
+		"pause":    "paused",
+		"schedule": "",
+	}[c.Param("action")]
+	if !ok {
+		adminError(c, &APIError{http.StatusNotFound, "unknown_action", "unknown action"})
+		return
+	}
+	if err := game.SetOverride(number-1, state); err != nil {
+		adminError(c, toAPIError(err))
+		return
+	}
+	adminDone(c, "question "+c.Param("action"), c.Param("number"), req.Reason)
+}
+
+func adminPause(c *gin.Context) {
+	req := adminRequest(c)
+	game.Pause(true)
+	adminDone(c, "pause", "", req.Reason)
+}
+
+func adminResume(c *gin.Context) {
+	req := adminRequest(c)
+	game.Pause(false)
+	adminDone(c, "resume", "", req.Reason)
+}
+
+func adminScore(c *gin.Context) {
+	req := adminRequest(c)
+	if req.Reason == "" {
+		adminError(c, errReasonMissing)
+		return
+	}
+	team := c.Param("team")
+	if err := ranking.SetScore(team, req.Number-1, req.Score); err != nil {
+		adminError(c, toAdminError(err))
+		return
+	}
+	candidates.SetScore(team, req.Number-1, req.Score)
+	adminDone(c, "score "+strconv.Itoa(req.Number)+"="+strconv.Itoa(req.Score), team, req.Reason)
+}
+
+func adminReset(c *gin.Context) {
+	req := adminRequest(c)
+	if req.Reason == "" {
+		adminError(c, errReasonMissing)
+		return
+	}
+	team := c.Param("team")
+	if err := ranking.Reset(team); err != nil {
+		adminError(c, toAdminError(err))
+		return
+	}
+	if err := candidates.Clear(team); err != nil {
+		logger.Error("candidate clear", "team", team, "error", err)
+	}
+	adminDone(c, "reset", team, req.Reason)
+}
+
+func adminBan(c *gin.Context) {
+	req := adminRequest(c)
+	if req.Reason == "" {
+		adminError(c, errReasonMissing)
+		return
+	}
+	team := c.Param("team")
+	if !isTeam(team) {
+		adminError(c, errUnknownTeam)
+		return
+	}
+	if err := bans.Ban(team, req.Reason); err != nil {
+		adminError(c, &APIError{http.StatusInternalServerError, "save_failed", err.Error()})
+		return
+	}
+	adminDone(c, "ban", team, req.Reason)
+}
+
+func adminUnban(c *gin.Context) {
+	req := adminRequest(c)
+	ok, err := bans.Unban(c.Param("team"))
+	if err != nil {
+		adminError(c, &APIError{http.StatusInternalServerError, "save_failed", err.Error()})
+		return
+	}
+	if !ok {
+		adminError(c, errNotBanned)
+		return
+	}
+	adminDone(c, "unban", c.Param("team"), req.Reason)
+}
+
+func adminSave(c *gin.Context) {
+	req := adminRequest(c)
+	if err := ranking.Backup("ranking_backup.json"); err != nil {
+		adminError(c, &APIError{http.StatusInternalServerError, "save_failed", err.Error()})
+		return
+	}
+	adminDone(c, "save", "ranking_backup.json", req.Reason)
+}
+
+func toAdminError(err error) *APIError {
+	switch err {
+	case ErrUnknownTeam:
+		return errUnknownTeam
+	case ErrNoSubmission:
+		return errNoSubmission
+	}
+	return toAPIError(err)
+}
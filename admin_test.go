@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// adminPost sends an admin action with token and a JSON body.
+func adminPost(r http.Handler, token, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.RemoteAddr = "10.0.0.1:1024"
+	req.Header.Set("X-Forwarded-For", "192.168.9.9")
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAuth(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10"})
+	for token, code := range map[string]int{
+		"":                 http.StatusUnauthorized,
+		"change me":        http.StatusUnauthorized,
+		"k7Qw9zX2pL4mN8vR": http.StatusOK,
+	} {
+		if w := adminPost(r, token, "/admin/game/pause", ""); w.Code != code {
+			t.Errorf("token %q: got %d, want %d", token, w.Code, code)
+		}
+	}
+	if list := audit.List(); len(list) != 1 || list[0].Remote != "10.0.0.1" {
+		t.Errorf("audit must record the remote address only: %+v", list)
+	}
+	config.Admin.Token = "change me"
+	if w := adminPost(r, "change me", "/admin/game/resume", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("a weak token must keep /admin closed: got %d", w.Code)
+	}
+}
+
+func TestAdminBan(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10"})
+	const token = "k7Qw9zX2pL4mN8vR"
+	for _, c := range []struct {
+		path, body string
+		code       int
+	}{
+		{"/admin/teams/a/ban", `{}`, http.StatusBadRequest},
+		{"/admin/teams/nobody/ban", `{"reason": "x"}`, http.StatusNotFound},
+		{"/admin/teams/b/unban", `{}`, http.StatusNotFound},
+		{"/admin/teams/a/ban", `{"reason": "scanning"}`, http.StatusOK},
+	} {
+		if w := adminPost(r, token, c.path, c.body); w.Code != c.code {
+			t.Errorf("%s %s: got %d %s, want %d", c.path, c.body, w.Code, w.Body, c.code)
+		}
+	}
+	if w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped("01\n10\n")); w.Code != http.StatusForbidden {
+		t.Errorf("a banned team must not answer, got %d", w.Code)
+	}
+
+	bl, err := NewBanListFromFile("bans_backup.json")
+	if err != nil || !bl.Banned("a") {
+		t.Errorf("bans must survive a restart: %v", err)
+	}
+	if w := adminPost(r, token, "/admin/teams/a/unban", `{}`); w.Code != http.StatusOK || bans.Banned("a") {
+		t.Errorf("unban: got %d", w.Code)
+	}
+}
+
+func TestAdminScore(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10"}, QuestionConfig{Map: "01 10"})
+	const token = "k7Qw9zX2pL4mN8vR"
+	if w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped("01\n10\n")); w.Code != http.StatusOK {
+		t.Fatalf("answer: got %d %s", w.Code, w.Body)
+	}
+	for _, c := range []struct {
+		path, body string
+		code       int
+	}{
+		{"/admin/teams/a/score", `{"number": 2, "score": 3}`, http.StatusBadRequest},
+		{"/admin/teams/b/score", `{"number": 2, "score": 3, "reason": "x"}`, http.StatusNotFound},
+		{"/admin/teams/a/score", `{"number": 3, "score": 3, "reason": "x"}`, http.StatusNotFound},
+		{"/admin/teams/a/score", `{"number": 2, "score": 3, "reason": "appeal"}`, http.StatusOK},
+	} {
+		if w := adminPost(r, token, c.path, c.body); w.Code != c.code {
+			t.Errorf("%s %s: got %d %s, want %d", c.path, c.body, w.Code, w.Body, c.code)
+		}
+	}
+	if leader, _ := ranking.Leader(); leader.TotalScore != 7 {
+		t.Errorf("total is %d, want 7", leader.TotalScore)
+	}
+	if w := adminPost(r, token, "/admin/teams/a/reset", `{"reason": "cheating"}`); w.Code != http.StatusOK {
+		t.Errorf("reset: got %d", w.Code)
+	}
+	if leader, _ := ranking.Leader(); leader.TotalScore != 0 {
+		t.Errorf("total is %d after a reset", leader.TotalScore)
+	}
+}
+
+func TestDashboardTeamPath(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10"})
+	setTeams([]TeamConfig{{Name: "x/y?z#", Address: "192.168.3."}})
+	req := httptest.NewRequest("GET", "/admin/dashboard", nil)
+	req.RemoteAddr = "10.0.0.1:1024"
+	req.AddCookie(&http.Cookie{Name: "admin_token", Value: "k7Qw9zX2pL4mN8vR"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	for _, want := range []string{`data-post="/admin/teams/x%2Fy%3Fz%23/ban"`, `data-post="/admin/teams/x%2Fy%3Fz%23/score" data-score`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("dashboard lacks %s", want)
+		}
+	}
+	if w := adminPost(r, "k7Qw9zX2pL4mN8vR", "/admin/teams/x%2Fy%3Fz%23/ban", `{"reason": "x"}`); w.Code != http.StatusOK || !bans.Banned("x/y?z#") {
+		t.Errorf("ban: got %d %s", w.Code, w.Body)
+	}
+}
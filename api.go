@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError is an error returned to players with a stable code that
+// clients can rely on.
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var (
+	errInvalidSize     = &APIError{http.StatusUnprocessableEntity, "invalid_size", "invalid image size"}
+	errNotOpen         = &APIError{http.StatusForbidden, "not_open", "question is not open"}
+	errRateLimited     = &APIError{http.StatusTooManyRequests, "rate_limited", "request is too many"}
+	errBadEncoding     = &APIError{http.StatusBadRequest, "bad_encoding", "request body is not a gzipped image"}
+	errUnknownQuestion = &APIError{http.StatusNotFound, "unknown_question", "unknown question"}
+	errTooLarge        = &APIError{http.StatusRequestEntityTooLarge, "too_large", "request body is too large"}
+	errPaused          = &APIError{http.StatusServiceUnavailable, "paused", "question is paused"}
+	errBanned          = &APIError{http.StatusForbidden, "banned", "your team is banned"}
+	errNotStarted      = &APIError{http.StatusForbidden, "not_started", "game has not started yet"}
+)
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// withDetail returns a copy of e whose message carries detail.
+func (e *APIError) withDetail(detail string) *APIError {
+	n := *e
+	n.Message += " (" + detail + ")"
+	return &n
+}
+
+// toAPIError maps errors of the game to their API counterpart.
+func toAPIError(err error) *APIError {
+	var e *APIError
+	switch {
+	case err == ErrInvalidSize:
+		return errInvalidSize
+	case errors.Is(err, ErrInvalidSize):
+		return &APIError{errInvalidSize.Status, errInvalidSize.Code, err.Error()}
+	case err == ErrNotOpen:
+		return errNotOpen
+	case err == ErrUnknownQuestion:
+		return errUnknownQuestion
+	case err == ErrPaused:
+		return errPaused
+	case err == ErrNotStarted:
+		return errNotStarted
+	case errors.As(err, &e):
+		return e
+	}
+	return errBadEncoding.withDetail(err.Error())
+}
+
+// AnswerRequest is the JSON form of a candidate image, one row of 0 and 1
+// per line. It is accepted when the request is sent as application/json.
+type AnswerRequest struct {
+	Map [][]int `json:"map"`
+}
+
+type AnswerResponse struct {
+	Wrong int    `json:"wrong"`
+	Score int    `json:"score"`
+	Flag  string `json:"flag,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+func apiAnswer(c *gin.Context) {
+	resp, err := submitAnswer(getIpAddr(c.Request), c.Param("number"), c.Request)
+	if err != nil {
+		retryAfter(c, err)
+		c.JSON(err.Status, ErrorResponse{Error: err.localize(requestLang(c.Request))})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func apiQuestions(c *gin.Context) {
+	c.JSON(http.StatusOK, game.Info(clock.Now()))
+}
+
+// TimeResponse lets clients count down with the clock of the server.
+type TimeResponse struct {
+	Now   time.Time  `json:"now"`
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+	Round *RoundInfo `json:"round,omitempty"`
+}
+
+func apiTime(c *gin.Context) {
+	start, end := game.Period()
+	resp := TimeResponse{
+		Now:   clock.Now(),
+		Start: start,
+	}
+	if !end.IsZero() {
+		resp.End = &end
+	}
+	resp.Round = current(game.Rounds(resp.Now))
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testServer starts a game of qs, opened an hour ago on a fake clock, for
+// the teams a at 192.168.1. and b at 192.168.2. in a scratch directory and
+// returns its router.
+func testServer(t *testing.T, qs ...QuestionConfig) (*gin.Engine, *FakeClock) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	gin.SetMode(gin.TestMode)
+	logger, _ = newLogger("error", "stderr")
+	fake := NewFakeClock(time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	c := &Config{Questions: qs}
+	c.Game.Start = fake.Now().Add(-time.Hour)
+	c.Game.Interval = 1
+	c.Admin.Token = "k7Qw9zX2pL4mN8vR"
+	c.Teams = []TeamConfig{{Name: "a", Address: "192.168.1."}, {Name: "b", Address: "192.168.2."}}
+	config = c
+	clock = fake
+	t.Cleanup(func() { clock = realClock{} })
+	setTeams(c.Teams)
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := loadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRouter(tmpl), fake
+}
+
+// gzipped compresses s like the clients do.
+func gzipped(s string) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte(s))
+	gz.Close()
+	return buf
+}
+
+// serve sends a request from ip to r and returns the recorded response.
+func serve(r http.Handler, ip, method, path, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+	req := httptest.NewRequest(method, path, body)
+	req.RemoteAddr = ip + ":1024"
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAnswerRaggedJSON(t *testing.T) {
+	r, fake := testServer(t, QuestionConfig{Map: "0110 1001 1001 0110", Flag: "SECCON{x}"})
+	for _, body := range []string{
+		`{"map": [[0, 1, 1, 0], [], [], []]}`,
+		`{"map": [[0, 1, 1, 0], [1, 0, 0, 1], [1, 0], [0, 1, 1, 0]]}`,
+		`{"map": [[0, 1, 1, 0], [1, 0, 0, 1], [1, 0, 0, 1, 1], [0, 1, 1, 0]]}`,
+		`{"map": [[0, 1, 1, 0], [1, 0, 0, 1], [1, 0, 0, 1]]}`,
+	} {
+		fake.Add(time.Second)
+		w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "application/json", gzipped(body))
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusUnprocessableEntity || resp.Error == nil || resp.Error.Code != "invalid_size" {
+			t.Errorf("%s: got %d %s", body, w.Code, w.Body)
+		}
+	}
+	if _, ok := ranking.Leader(); ok {
+		t.Error("a ragged map must not be scored")
+	}
+}
+
+// apiError returns the code of the error answered in w.
+func apiError(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == nil {
+		t.Fatalf("not an error response: %d %s", w.Code, w.Body)
+	}
+	return resp.Error.Code
+}
+
+func TestAnswerErrors(t *testing.T) {
+	r, fake := testServer(t, QuestionConfig{Map: "0110 1001 1001 0110", Flag: "SECCON{x}"})
+	for _, tt := range []struct {
+		name string
+		body *bytes.Buffer
+		code string
+		want int
+	}{
+		{"gzip bomb", gzipped("0110\n1001\n1001\n0110\n" + strings.Repeat("\n", 1<<20)), "too_large", http.StatusRequestEntityTooLarge},
+		{"plain text", bytes.NewBufferString("0110\n1001\n1001\n0110\n"), "bad_encoding", http.StatusBadRequest},
+		{"short rows", gzipped("01\n10\n01\n10\n"), "invalid_size", http.StatusUnprocessableEntity},
+	} {
+		fake.Add(time.Second)
+		w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", tt.body)
+		if code := apiError(t, w); w.Code != tt.want || code != tt.code {
+			t.Errorf("%s: got %d %s, want %d %s", tt.name, w.Code, code, tt.want, tt.code)
+		}
+	}
+}
+
+func TestAnswerRateLimited(t *testing.T) {
+	r, fake := testServer(t, QuestionConfig{Map: "0110 1001 1001 0110", Flag: "SECCON{x}"})
+	fake.Add(time.Second)
+	if w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped("0110\n1001\n1001\n0110\n")); w.Code != http.StatusOK {
+		t.Fatalf("first answer: %d %s", w.Code, w.Body)
+	}
+	w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped("0110\n1001\n1001\n0110\n"))
+	if code := apiError(t, w); w.Code != http.StatusTooManyRequests || code != "rate_limited" {
+		t.Errorf("got %d %s", w.Code, code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if w := serve(r, "192.168.2.1", "POST", "/api/v1/answer/1", "", gzipped("0110\n1001\n1001\n0110\n")); w.Code != http.StatusOK {
+		t.Errorf("other team: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAnswerNotOpen(t *testing.T) {
+	r, fake := testServer(t,
+		QuestionConfig{Map: "0110 1001 1001 0110", Flag: "SECCON{x}", Open: 7200},
+		QuestionConfig{Map: "0110 1001 1001 0110", Flag: "SECCON{y}", Close: 1800})
+	for _, number := range []string{"1", "2"} {
+		fake.Add(time.Second)
+		w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/"+number, "", gzipped("0110\n1001\n1001\n0110\n"))
+		if code := apiError(t, w); w.Code != http.StatusForbidden || code != "not_open" {
+			t.Errorf("question %s: got %d %s", number, w.Code, code)
+		}
+	}
+
+	fake.Add(-2 * time.Hour)
+	w := serve(r, "192.168.2.1", "POST", "/api/v1/answer/1", "", gzipped("0110\n1001\n1001\n0110\n"))
+	if code := apiError(t, w); w.Code != http.StatusForbidden || code != "not_started" {
+		t.Errorf("before the game: got %d %s", w.Code, code)
+	}
+}
+
+func TestApiTime(t *testing.T) {
+	r, fake := testServer(t, QuestionConfig{Map: "0110 1001 1001 0110", Flag: "SECCON{x}"})
+	fake.Add(90 * time.Second)
+	w := serve(r, "192.168.1.1", "GET", "/api/time", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var resp TimeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Now.Equal(fake.Now()) {
+		t.Errorf("now = %v, want %v", resp.Now, fake.Now())
+	}
+	if want := fake.Now().Add(-time.Hour - 90*time.Second); !resp.Start.Equal(want) {
+		t.Errorf("start = %v, want %v", resp.Start, want)
+	}
+	if resp.End != nil {
+		t.Errorf("end = %v, want none", resp.End)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errNoSubmission = &APIError{http.StatusNotFound, "no_submission", "team has no submission for this question"}
+
+// CandidateArchive keeps the best candidate of each team per question, a
+// text file each under dir, apart from the ranking.
+type CandidateArchive struct {
+	dir string
+	// scores are those of the candidates stored since the start by team
+	// and question, so that a late write never replaces a better one.
+	scores map[string]map[int]int
+	mu     *sync.Mutex
+}
+
+func NewCandidateArchive(dir string) *CandidateArchive {
+	return &CandidateArchive{
+		dir:    dir,
+		scores: make(map[string]map[int]int),
+		mu:     &sync.Mutex{},
+	}
+}
+
+// teamDir is the directory of team under dir. Names are path-escaped and
+// a leading dot is encoded too, so that "." and ".." stay inside.
+func (ca *CandidateArchive) teamDir(team string) string {
+	name := url.PathEscape(team)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + strings.TrimPrefix(name, ".")
+	}
+	return filepath.Join(ca.dir, name)
+}
+
+func (ca *CandidateArchive) path(team string, number int) string {
+	return filepath.Join(ca.teamDir(team), strconv.Itoa(number+1)+".txt")
+}
+
+// Store keeps m, which scored score, as the best candidate of team for
+// question number.
+func (ca *CandidateArchive) Store(team string, number, score int, m Map) error {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if best, ok := ca.scores[team][number]; ok && best >= score {
+		return nil
+	}
+	path := ca.path(team, number)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, []byte(m.Format("\n"))); err != nil {
+		return err
+	}
+	if ca.scores[team] == nil {
+		ca.scores[team] = make(map[int]int)
+	}
+	ca.scores[team][number] = score
+	return nil
+}
+
+// SetScore follows an override of the score of team on question number,
+// so that the next better candidate is stored even below the old best.
+func (ca *CandidateArchive) SetScore(team string, number, score int) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if _, ok := ca.scores[team][number]; ok {
+		ca.scores[team][number] = score
+	}
+}
+
+// Load returns the best candidate of team for question number.
+func (ca *CandidateArchive) Load(team string, number int) (Map, error) {
+	buf, err := ioutil.ReadFile(ca.path(team, number))
+	if os.IsNotExist(err) {
+		return nil, ErrNoSubmission
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseMapString(string(buf), "\n")
+}
+
+// Clear forgets every candidate of team.
+func (ca *CandidateArchive) Clear(team string) error {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	delete(ca.scores, team)
+	return os.RemoveAll(ca.teamDir(team))
+}
+
+// archivePalette colors the rendered images: dots, wrong dots and the gap
+// between the panels of a comparison.
+var archivePalette = color.Palette{
+	color.White,
+	color.Black,
+	color.RGBA{0xe0, 0x20, 0x20, 0xff},
+	color.RGBA{0xff, 0xc0, 0xc0, 0xff},
+	color.RGBA{0xc0, 0xc0, 0xc0, 0xff},
+}
+
+const (
+	paletteWhite = iota
+	paletteBlack
+	paletteWrongBlack
+	paletteWrongWhite
+	paletteGap
+)
+
+// renderMap draws m, marking the dots which differ from hidden in red when
+// hidden is not nil.
+func renderMap(img *image.Paletted, x0, scale int, m, hidden Map) {
+	for i, row := range m {
+		for j, dot := range row {
+			c := uint8(paletteWhite)
+			if dot {
+				c = paletteBlack
+			}
+			if hidden != nil && dot != hidden[i][j] {
+				c = paletteWrongWhite
+				if dot {
+					c = paletteWrongBlack
+				}
+			}
+			for y := 0; y < scale; y++ {
+				for x := 0; x < scale; x++ {
+					img.SetColorIndex(x0+j*scale+x, i*scale+y, c)
+				}
+			}
+		}
+	}
+}
+
+// renderArchive draws the candidate m of a question whose image is hidden.
+// mode is candidate, diff for the wrong dots in red, or compare for the
+// candidate, the hidden image and the diff side by side.
+func renderArchive(m, hidden Map, mode string, scale int) (image.Image, bool) {
+	w, h := len(m[0])*scale, len(m)*scale
+	panels := map[string][]Map{
+		"candidate": {m, nil},
+		"diff":      {m, hidden},
+		"compare":   {m, nil, hidden, nil, m, hidden},
+	}[mode]
+	if panels == nil {
+		return nil, false
+	}
+	n := len(panels) / 2
+	img := image.NewPaletted(image.Rect(0, 0, n*w+(n-1)*scale, h), archivePalette)
+	for i := 0; i < n; i++ {
+		x0 := i * (w + scale)
+		if i > 0 {
+			for y := 0; y < h; y++ {
+				for x := x0 - scale; x < x0; x++ {
+					img.SetColorIndex(x, y, paletteGap)
+				}
+			}
+		}
+		renderMap(img, x0, scale, panels[2*i], panels[2*i+1])
+	}
+	return img, true
+}
+
+// adminArchive renders the best candidate of a team as a PNG.
+func adminArchive(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		adminError(c, errUnknownQuestion)
+		return
+	}
+	number -= 1
+	q, err := game.Question(number)
+	if err != nil {
+		adminError(c, toAPIError(err))
+		return
+	}
+	team := c.Param("team")
+	if !isTeam(team) {
+		adminError(c, errUnknownTeam)
+		return
+	}
+	m, err := candidates.Load(team, number)
+	if err != nil {
+		adminError(c, toAdminError(err))
+		return
+	}
+	if w, h := q.Size(); w != len(m[0]) || h != len(m) {
+		adminError(c, errInvalidSize)
+		return
+	}
+	scale, _ := strconv.Atoi(c.DefaultQuery("scale", "4"))
+	if scale < 1 || scale > 16 {
+		scale = 4
+	}
+	mode := c.DefaultQuery("mode", "candidate")
+	img, ok := renderArchive(m, q.hMap, mode, scale)
+	if !ok {
+		adminError(c, &APIError{http.StatusBadRequest, "bad_mode", "mode is candidate, diff or compare"})
+		return
+	}
+	audit.Record(getIpAddr(c.Request), "archive "+mode, team, "", c.Param("number"))
+	c.Header("Content-Type", "image/png")
+	png.Encode(c.Writer, img)
+}
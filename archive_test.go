@@ -0,0 +1,108 @@
+package main
+
+import (
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderArchive(t *testing.T) {
+	hidden, _ := parseMapString("10 01", " ")
+	m, _ := parseMapString("11 01", " ")
+	if m.Format(" ") != "11 01" {
+		t.Fatalf("Format is %q", m.Format(" "))
+	}
+
+	img, ok := renderArchive(m, hidden, "diff", 2)
+	if !ok {
+		t.Fatal("diff must be rendered")
+	}
+	p := img.(*image.Paletted)
+	if p.Bounds().Dx() != 4 || p.Bounds().Dy() != 4 {
+		t.Errorf("unexpected size %v", p.Bounds())
+	}
+	if p.ColorIndexAt(0, 0) != paletteBlack || p.ColorIndexAt(3, 1) != paletteWrongBlack {
+		t.Error("wrong dots must be red")
+	}
+
+	img, _ = renderArchive(m, hidden, "compare", 2)
+	if img.Bounds().Dx() != 3*4+2*2 {
+		t.Errorf("unexpected compare width %d", img.Bounds().Dx())
+	}
+	if _, ok := renderArchive(m, hidden, "nope", 2); ok {
+		t.Error("unknown mode must fail")
+	}
+}
+
+func TestArchiveRestart(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "0110 1001", Flag: "SECCON{1}"})
+	serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped("0110\n1000\n"))
+	if err := candidates.Store("a", 0, 1, Map{{true, true, true, true}, {true, true, true, true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restart reads the ranking and the candidates back
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	if leader, ok := ranking.Leader(); !ok || leader.Name != "a" || leader.TotalScore != 7 {
+		t.Errorf("the ranking must survive a restart: %+v", leader)
+	}
+	m, err := candidates.Load("a", 0)
+	if err != nil || m.Format(" ") != "0110 1000" {
+		t.Errorf("the best candidate must survive a restart: %v %v", m, err)
+	}
+	if _, err := candidates.Load("b", 0); err != ErrNoSubmission {
+		t.Errorf("got %v, want ErrNoSubmission", err)
+	}
+	req := httptest.NewRequest("GET", "/admin/archive/a/1", nil)
+	req.RemoteAddr = "10.0.0.1:1024"
+	req.Header.Set("Authorization", "Bearer k7Qw9zX2pL4mN8vR")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("archive: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestCandidateArchive(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ca := NewCandidateArchive("archive")
+	m := Map{{true, false}, {false, true}}
+	for _, team := range []string{".", "..", "a/b", "../x"} {
+		if err := ca.Store(team, 0, 1, m); err != nil {
+			t.Fatal(err)
+		}
+		rel, err := filepath.Rel("archive", ca.path(team, 0))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") || filepath.Dir(rel) == "." {
+			t.Errorf("%q is stored at %s, out of its directory in the archive", team, ca.path(team, 0))
+		}
+		if got, err := ca.Load(team, 0); err != nil || got.Format(" ") != "10 01" {
+			t.Errorf("%q: got %v %v", team, got, err)
+		}
+	}
+	if _, err := os.Stat("1.txt"); !os.IsNotExist(err) {
+		t.Error("a candidate was written outside the archive")
+	}
+	if err := ca.Clear(".."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("archive"); err != nil {
+		t.Error("clearing .. must not remove the archive")
+	}
+
+	// an override below the best lets the next better candidate in
+	ca.Store("a", 0, 10, m)
+	ca.SetScore("a", 0, 2)
+	better := Map{{true, true}, {true, true}}
+	if err := ca.Store("a", 0, 5, better); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ca.Load("a", 0); got.Format(" ") != "11 11" {
+		t.Errorf("the candidate after an override must be stored, got %v", got)
+	}
+}
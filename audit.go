@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditLog keeps every action of organizers, both in memory and appended
+// to a file as one JSON object per line.
+type AuditLog struct {
+	Entries []AuditEntry
+	path    string
+	mu      *sync.Mutex
+}
+
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Remote string    `json:"remote"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	// Detail holds what must not go to the normal log, such as flags.
+	Detail string `json:"detail,omitempty"`
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{
+		Entries: []AuditEntry{},
+		path:    path,
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (al *AuditLog) Record(remote, action, target, reason, detail string) {
+	e := AuditEntry{
+		Time:   clock.Now(),
+		Remote: remote,
+		Action: action,
+		Target: target,
+		Reason: reason,
+		Detail: detail,
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.Entries = append(al.Entries, e)
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		logger.Error("audit", "error", err)
+		return
+	}
+	f, err := os.OpenFile(al.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		logger.Error("audit", "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(buf, '\n')); err != nil {
+		logger.Error("audit", "error", err)
+	}
+}
+
+// List returns a copy of the entries recorded so far.
+func (al *AuditLog) List() []AuditEntry {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	return append([]AuditEntry{}, al.Entries...)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// bayer4 is the threshold map of ordered dithering, in sixteenths.
+var bayer4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// grayscale returns the luminance of img resized to width x height, 0 for
+// black and 1 for white, averaging the pixels under each dot. Transparent
+// pixels are white.
+func grayscale(img image.Image, width, height int) [][]float64 {
+	b := img.Bounds()
+	gray := make([][]float64, height)
+	for y := range gray {
+		gray[y] = make([]float64, width)
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := range gray[y] {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+			sum := 0.0
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(sx, sy)).(color.NRGBA64)
+					l := (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 0xffff
+					a := float64(c.A) / 0xffff
+					sum += l*a + 1 - a
+				}
+			}
+			gray[y][x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return gray
+}
+
+// binarize turns the dots of gray darker than threshold into ink. dither
+// is none, floyd-steinberg or ordered.
+func binarize(gray [][]float64, threshold float64, dither string) (Map, error) {
+	m := make(Map, len(gray))
+	errs := make([][]float64, len(gray)+1)
+	for y := range errs {
+		errs[y] = make([]float64, len(gray[0])+2)
+	}
+	for y, row := range gray {
+		m[y] = make([]bool, len(row))
+		for x, v := range row {
+			switch dither {
+			case "none":
+				m[y][x] = v < threshold
+			case "ordered":
+				m[y][x] = v < threshold+(bayer4[y%4][x%4]+0.5)/16-0.5
+			case "floyd-steinberg":
+				v += errs[y][x+1]
+				m[y][x] = v < threshold
+				// what is left of v once drawn as ink, 0, or paper, 1
+				if !m[y][x] {
+					v -= 1
+				}
+				errs[y][x+2] += v * 7 / 16
+				errs[y+1][x] += v * 3 / 16
+				errs[y+1][x+1] += v * 5 / 16
+				errs[y+1][x+2] += v * 1 / 16
+			default:
+				return nil, fmt.Errorf("unknown dither %q", dither)
+			}
+		}
+	}
+	return m, nil
+}
+
+// ink returns the number of ink dots of m.
+func (m Map) ink() int {
+	n := 0
+	for _, row := range m {
+		for _, dot := range row {
+			if dot {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// mixedBlocks counts the blocks of size dots a side holding both ink and
+// paper, which cost the adaptive client most.
+func (m Map) mixedBlocks(size int) (mixed, blocks int) {
+	for top := 0; top < len(m); top += size {
+		for left := 0; left < len(m[0]); left += size {
+			ink, paper := false, false
+			for y := top; y < min(top+size, len(m)); y++ {
+				for x := left; x < min(left+size, len(m[0])); x++ {
+					ink = ink || m[y][x]
+					paper = paper || !m[y][x]
+				}
+			}
+			if ink && paper {
+				mixed++
+			}
+			blocks++
+		}
+	}
+	return mixed, blocks
+}
+
+// writeQuestion writes m as a question in format: config for an item of
+// questions in the config, file for a file of include/questions or map
+// for a mapfile.
+func writeQuestion(w io.Writer, m Map, format, flag string, open int) error {
+	rows := m.Format("\n")
+	switch format {
+	case "map":
+		_, err := fmt.Fprintln(w, rows)
+		return err
+	case "config":
+		_, err := fmt.Fprintf(w, "  - open: %d\n    flag: %q\n    map: \"%s\"\n", open, flag, rows)
+		return err
+	case "file":
+		_, err := fmt.Fprintf(w, "open: %d\nflag: %q\nmap: \"%s\"\n", open, flag, rows)
+		return err
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// cmdAuthor turns an image into a question and tells how hard it looks.
+func cmdAuthor(args []string) int {
+	fs := flag.NewFlagSet("author", flag.ExitOnError)
+	width := fs.Int("width", 130, "dots per row")
+	height := fs.Int("height", 130, "rows")
+	threshold := fs.Float64("threshold", 0.5, "luminance from 0 to 1 below which a dot is ink")
+	dither := fs.String("dither", "none", "none, floyd-steinberg or ordered")
+	invert := fs.Bool("invert", false, "swap ink and paper")
+	preview := fs.String("preview", "ascii", "ascii to print the dots to stderr, none to skip")
+	pngPath := fs.String("png", "", "file to write a PNG preview to")
+	scale := fs.Int("scale", 4, "pixels per dot of the PNG preview")
+	format := fs.String("format", "config", "config, file or map")
+	flagValue := fs.String("flag", "SECCON{change_me}", "flag of the question")
+	open := fs.Int("open", 0, "seconds from the start of the game until the question opens")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: author [flags] IMAGE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *width < 1 || *height < 1 || *scale < 1 || *format != "config" && *format != "file" && *format != "map" {
+		fs.Usage()
+		return 2
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", fs.Arg(0), err)
+		return 1
+	}
+	m, err := binarize(grayscale(img, *width, *height), *threshold, *dither)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *invert {
+		for _, row := range m {
+			for x := range row {
+				row[x] = !row[x]
+			}
+		}
+	}
+
+	if *preview == "ascii" {
+		r := strings.NewReplacer("0", ".", "1", "#")
+		fmt.Fprintln(os.Stderr, r.Replace(m.Format("\n")))
+	}
+	if *pngPath != "" {
+		out, err := os.Create(*pngPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		preview, _ := renderArchive(m, nil, "candidate", *scale)
+		err = png.Encode(out, preview)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if err := writeQuestion(os.Stdout, m, *format, *flagValue, *open); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	dots, ink := *width**height, m.ink()
+	fmt.Fprintf(os.Stderr, "%d x %d dots, %d ink (%.1f%%)\n", *width, *height, ink, 100*float64(ink)/float64(dots))
+	fmt.Fprintf(os.Stderr, "all paper guess: %d wrong, score %d\n", ink, dots-ink)
+	fmt.Fprintf(os.Stderr, "all ink guess: %d wrong, score %d\n", dots-ink, ink)
+	fmt.Fprintf(os.Stderr, "flag below %d wrong at the default threshold\n", int(0.1*float64(dots)))
+	mixed, blocks := m.mixedBlocks(8)
+	fmt.Fprintf(os.Stderr, "8 x 8 blocks holding both ink and paper: %d of %d\n", mixed, blocks)
+	return 0
+}
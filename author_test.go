@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestAuthor(t *testing.T) {
+	// a black left half on white, 8 x 4 pixels
+	img := image.NewGray(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetGray(x, y, color.Gray{0xff})
+			if x < 4 {
+				img.SetGray(x, y, color.Gray{0})
+			}
+		}
+	}
+	m, err := binarize(grayscale(img, 4, 2), 0.5, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Format(" ") != "1100 1100" || m.ink() != 4 {
+		t.Errorf("unexpected map %q", m.Format(" "))
+	}
+	if _, err := binarize(grayscale(img, 4, 2), 0.5, "nope"); err == nil {
+		t.Error("unknown dither must fail")
+	}
+
+	var buf bytes.Buffer
+	if err := writeQuestion(&buf, m, "file", "SECCON{x}", 60); err != nil {
+		t.Fatal(err)
+	}
+	var qc QuestionConfig
+	if err := yaml.Unmarshal(buf.Bytes(), &qc); err != nil {
+		t.Fatal(err)
+	}
+	if qc.Map != "1100 1100" || qc.Flag != "SECCON{x}" || qc.Open != 60 {
+		t.Errorf("unexpected question %+v", qc)
+	}
+
+	buf.Reset()
+	writeQuestion(&buf, m, "config", "SECCON{x}", 0)
+	var c Config
+	if err := yaml.Unmarshal([]byte("questions:\n"+buf.String()), &c); err != nil || len(c.Questions) != 1 || c.Questions[0].Map != "1100 1100" {
+		t.Errorf("unexpected config %q: %v", buf.String(), err)
+	}
+	if !strings.HasPrefix(buf.String(), "  - ") {
+		t.Error("config must be a list item")
+	}
+}
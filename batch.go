@@ -0,0 +1,205 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchRequest is the JSON form of a batch, sent gzipped as
+// application/json. A batch may also be sent as multipart/form-data with
+// one gzipped text image per part.
+type BatchRequest []AnswerRequest
+
+type BatchResponse struct {
+	Results []AnswerResponse `json:"results"`
+	// Best is the index of the candidate that counts for the ranking.
+	Best int `json:"best"`
+}
+
+var errTooManyCandidates = &APIError{http.StatusRequestEntityTooLarge, "too_many_candidates", "too many candidates"}
+
+func viewBatch(c *gin.Context) {
+	resp, err := submitBatch(getIpAddr(c.Request), c.Param("number"), c.Request)
+	if err != nil {
+		retryAfter(c, err)
+		c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"message": err.localize(requestLang(c.Request)).Message,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func apiBatch(c *gin.Context) {
+	resp, err := submitBatch(getIpAddr(c.Request), c.Param("number"), c.Request)
+	if err != nil {
+		retryAfter(c, err)
+		c.JSON(err.Status, ErrorResponse{Error: err.localize(requestLang(c.Request))})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// submitBatch scores every candidate in r and charges the rate limiter
+// for each of them. Only the best one is recorded on the ranking.
+func submitBatch(ipaddr, param string, r *http.Request) (*BatchResponse, *APIError) {
+	team := Ip2Team(ipaddr)
+	stats.Request(team)
+	if bans.Banned(team) {
+		return nil, errBanned
+	}
+	number, err := strconv.Atoi(param)
+	number -= 1
+	if err != nil {
+		return nil, errUnknownQuestion
+	}
+	q, err := game.Question(number)
+	if err != nil {
+		return nil, toAPIError(err)
+	}
+	// a batch for a question that takes no answers is not charged
+	if err := game.CheckOpen(number); err != nil {
+		return nil, toAPIError(err)
+	}
+	// a limited team is turned away before its body is decoded
+	if !iBreaker.Ready(team) {
+		stats.RateLimit(team)
+		requestLogger(r).Info("rate limited", "team", team, "question", number+1)
+		return nil, errRateLimited
+	}
+
+	maps, err := readBatch(r, q)
+	if err != nil {
+		apiErr := toAPIError(err)
+		metricParseFailures.WithLabelValues(apiErr.Code).Inc()
+		if apiErr.Code == errTooLarge.Code || apiErr.Code == errTooManyCandidates.Code {
+			requestLogger(r).Warn("abuse", "team", team, "remote", ipaddr, "question", number+1, "error", apiErr.Message)
+		}
+		return nil, apiErr
+	}
+	if len(maps) == 0 {
+		return nil, errBadEncoding.withDetail("no candidates")
+	}
+	if !iBreaker.CheckN(ipaddr, float64(len(maps))*q.batchCost) {
+		stats.RateLimit(team)
+		requestLogger(r).Info("rate limited", "team", team, "question", number+1, "candidates", len(maps))
+		return nil, errRateLimited
+	}
+	requestLogger(r).Debug("rate limit passed", "team", team, "question", number+1, "candidates", len(maps))
+
+	resp := &BatchResponse{Results: make([]AnswerResponse, len(maps))}
+	bestFlag := ""
+	for i, m := range maps {
+		score, wrong, flag, err := game.Try(m, number)
+		if err != nil {
+			return nil, toAPIError(err)
+		}
+		stats.Attempt(team, number, wrong)
+		resp.Results[i] = AnswerResponse{Wrong: wrong, Score: score}
+		if score > resp.Results[resp.Best].Score {
+			resp.Best = i
+		}
+		if i == resp.Best {
+			bestFlag = flag
+		}
+	}
+	best := &resp.Results[resp.Best]
+	requestLogger(r).Info("batch", "team", team, "question", number+1, "candidates", len(maps), "wrong", best.Wrong, "score", best.Score)
+	best.Flag = credit(r, ipaddr, number, best.Score, bestFlag, maps[resp.Best])
+	return resp, nil
+}
+
+// readBatch decodes up to q.batchMax candidate images for q.
+func readBatch(r *http.Request, q Question) ([]Map, error) {
+	defer r.Body.Close()
+	limit := maxBodySize()
+	body := &limitReader{r: r.Body, n: limit * int64(q.batchMax)}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return readMultipartBatch(body, params["boundary"], q, limit)
+	}
+	if mediaType != "application/json" {
+		return nil, errBadEncoding.withDetail("multipart or json expected")
+	}
+
+	gzipr, err := gzip.NewReader(body)
+	if err != nil {
+		if e, ok := err.(*APIError); ok {
+			return nil, e
+		}
+		return nil, errBadEncoding.withDetail("gzip error")
+	}
+	defer gzipr.Close()
+
+	var req BatchRequest
+	err = json.NewDecoder(&limitReader{r: gzipr, n: limit * int64(q.batchMax)}).Decode(&req)
+	if err != nil {
+		if e, ok := err.(*APIError); ok {
+			return nil, e
+		}
+		return nil, errBadEncoding.withDetail("json error")
+	}
+	if len(req) > q.batchMax {
+		return nil, errTooManyCandidates.withDetail(fmt.Sprintf("up to %d", q.batchMax))
+	}
+	width, height := q.Size()
+	maps := make([]Map, 0, len(req))
+	for i, a := range req {
+		m := a.toMap()
+		if err := m.checkSize(width, height); err != nil {
+			return nil, fmt.Errorf("candidate %d: %w", i+1, err)
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
+
+func readMultipartBatch(body io.Reader, boundary string, q Question, limit int64) ([]Map, error) {
+	if boundary == "" {
+		return nil, errBadEncoding.withDetail("no multipart boundary")
+	}
+	width, height := q.Size()
+	mr := multipart.NewReader(body, boundary)
+
+	maps := []Map{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return maps, nil
+		}
+		if err != nil {
+			if e, ok := err.(*APIError); ok {
+				return nil, e
+			}
+			return nil, errBadEncoding.withDetail("multipart error")
+		}
+		if len(maps) == q.batchMax {
+			return nil, errTooManyCandidates.withDetail(fmt.Sprintf("up to %d", q.batchMax))
+		}
+
+		gzipr, err := gzip.NewReader(part)
+		if err != nil {
+			if e, ok := err.(*APIError); ok {
+				return nil, e
+			}
+			return nil, errBadEncoding.withDetail(fmt.Sprintf("gzip error in candidate %d", len(maps)))
+		}
+		m, err := parseMapStream(&limitReader{r: gzipr, n: limit}, width, height)
+		gzipr.Close()
+		if err != nil {
+			apiErr := toAPIError(err)
+			return nil, &APIError{apiErr.Status, apiErr.Code, fmt.Sprintf("candidate %d: %s", len(maps), apiErr.Message)}
+		}
+		maps = append(maps, m)
+	}
+}
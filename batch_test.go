@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	r, fake := testServer(t, QuestionConfig{Map: "01 10", Flag: "SECCON{x}", Batch: 3})
+	post := func(body string) (int, BatchResponse, ErrorResponse) {
+		w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1/batch", "application/json", gzipped(body))
+		var ok BatchResponse
+		var e ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &ok)
+		json.Unmarshal(w.Body.Bytes(), &e)
+		return w.Code, ok, e
+	}
+
+	code, resp, _ := post(`[{"map": [[0, 0], [0, 0]]}, {"map": [[0, 1], [1, 0]]}, {"map": [[1, 1], [1, 1]]}]`)
+	if code != http.StatusOK || len(resp.Results) != 3 || resp.Best != 1 {
+		t.Fatalf("got %d %+v", code, resp)
+	}
+	if resp.Results[0].Score != 2 || resp.Results[1].Score != 4 || resp.Results[1].Flag == "" || resp.Results[0].Flag != "" {
+		t.Errorf("unexpected results %+v", resp.Results)
+	}
+	if leader, ok := ranking.Leader(); !ok || leader.TotalScore != 4 {
+		t.Errorf("the best candidate must be ranked: %+v", leader)
+	}
+
+	// three candidates cost three intervals, a limited team is turned
+	// away before its body is read
+	fake.Add(2 * time.Second)
+	if code, _, e := post("not even gzip"); code != http.StatusTooManyRequests || e.Error.Code != "rate_limited" {
+		t.Errorf("got %d %+v", code, e.Error)
+	}
+	fake.Add(time.Second)
+	for _, c := range []struct {
+		body string
+		code string
+	}{
+		{`[{"map": [[0, 1], [1, 0]]}, {"map": [[0, 1], []]}]`, "invalid_size"},
+		{`[{"map": [[0, 1], [1, 0]]}, {"map": [[0, 1], [1, 0]]}, {"map": [[0, 1], [1, 0]]}, {"map": [[0, 1], [1, 0]]}]`, "too_many_candidates"},
+		{`[]`, "bad_encoding"},
+	} {
+		if _, _, e := post(c.body); e.Error == nil || e.Error.Code != c.code {
+			t.Errorf("%s: got %+v, want %s", c.body, e.Error, c.code)
+		}
+	}
+	if code, _, _ := post(`[{"map": [[0, 1], [1, 0]]}]`); code != http.StatusOK {
+		t.Errorf("rejected batches must not be charged, got %d", code)
+	}
+}
+
+func TestBatchNotOpen(t *testing.T) {
+	r, _ := testServer(t,
+		QuestionConfig{Map: "01 10", Flag: "SECCON{x}", Batch: 3},
+		QuestionConfig{Map: "01 10", Flag: "SECCON{y}", Batch: 3, Open: 7200})
+	body := `[{"map": [[0, 1], [1, 0]]}, {"map": [[0, 1], [1, 0]]}, {"map": [[0, 1], [1, 0]]}]`
+	w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/2/batch", "application/json", gzipped(body))
+	if code := apiError(t, w); w.Code != http.StatusForbidden || code != "not_open" {
+		t.Errorf("got %d %s", w.Code, code)
+	}
+	if !iBreaker.Ready("a") {
+		t.Error("a batch to a question that is not open must not be charged")
+	}
+	if w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1/batch", "application/json", gzipped(body)); w.Code != http.StatusOK {
+		t.Errorf("got %d %s", w.Code, w.Body)
+	}
+}
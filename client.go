@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Block is a region of a question whose unsettled dots hold Ones ones, -1
+// until measured. A block with After waits for the block of that ID to be
+// measured and then holds Rest less the ones of that block.
+type Block struct {
+	ID     int        `json:"id"`
+	Region HintRegion `json:"region"`
+	Ones   int        `json:"ones"`
+	After  int        `json:"after,omitempty"`
+	Rest   int        `json:"rest,omitempty"`
+}
+
+// SolverState is the progress of the client on a question, saved after
+// every response to resume from.
+//
+// Every candidate holds the settled dots and sets the unsettled ones to 0
+// but those of the block measured, so its wrong count tells the ones of
+// the block.
+type SolverState struct {
+	Number   int    `json:"number"`
+	Strategy string `json:"strategy"`
+	Size     int    `json:"size"`
+	// Dots holds a row of 0, 1 or ? per line, ? for the unsettled dots.
+	Dots []string `json:"dots"`
+	// Ones is the number of ones among the unsettled dots, -1 until the
+	// first response, and Wrong the wrong dots among the settled ones.
+	Ones       int     `json:"ones"`
+	Wrong      int     `json:"wrong"`
+	Blocks     []Block `json:"blocks"`
+	NextID     int     `json:"next_id"`
+	Requests   int     `json:"requests"`
+	Candidates int     `json:"candidates"`
+	// Best is the lowest wrong count answered, -1 before any.
+	Best int    `json:"best"`
+	Flag string `json:"flag,omitempty"`
+}
+
+// newSolverState starts question info over with strategy, which tiles the
+// image with blocks of size dots a side. Dots revealed by hints are
+// settled right away.
+func newSolverState(info QuestionInfo, strategy string, size int) *SolverState {
+	if strategy == "pixel" {
+		size = 1
+	}
+	s := &SolverState{
+		Number:   info.Number,
+		Strategy: strategy,
+		Size:     size,
+		Dots:     make([]string, info.Height),
+		Ones:     -1,
+		NextID:   1,
+		Best:     -1,
+	}
+	for y := range s.Dots {
+		s.Dots[y] = strings.Repeat("?", info.Width)
+	}
+	for _, h := range info.Hints {
+		if h.Region == nil {
+			continue
+		}
+		for y, row := range h.Map {
+			dots := []byte(s.Dots[h.Region.Top+y])
+			copy(dots[h.Region.Left:], row)
+			s.Dots[h.Region.Top+y] = string(dots)
+		}
+	}
+	for top := 0; top < info.Height; top += size {
+		for left := 0; left < info.Width; left += size {
+			s.add(HintRegion{top, left, min(top+size, info.Height), min(left+size, info.Width)})
+		}
+	}
+	return s
+}
+
+func (s *SolverState) add(r HintRegion) *Block {
+	s.Blocks = append(s.Blocks, Block{ID: s.NextID, Region: r, Ones: -1})
+	s.NextID++
+	return &s.Blocks[len(s.Blocks)-1]
+}
+
+// count returns the number of unsettled dots in r.
+func (s *SolverState) count(r HintRegion) int {
+	n := 0
+	for y := r.Top; y < r.Bottom; y++ {
+		n += strings.Count(s.Dots[y][r.Left:r.Right], "?")
+	}
+	return n
+}
+
+// settle sets the unsettled dots in r to dot.
+func (s *SolverState) settle(r HintRegion, dot byte) {
+	for y := r.Top; y < r.Bottom; y++ {
+		dots := []byte(s.Dots[y])
+		for x := r.Left; x < r.Right; x++ {
+			if dots[x] == '?' {
+				dots[x] = dot
+			}
+		}
+		s.Dots[y] = string(dots)
+	}
+}
+
+// candidate returns the settled dots with the unsettled ones of b set to
+// 1, b may be nil.
+func (s *SolverState) candidate(b *Block) Map {
+	m := make(Map, len(s.Dots))
+	for y, row := range s.Dots {
+		m[y] = make([]bool, len(row))
+		for x := range row {
+			m[y][x] = row[x] == '1'
+			if row[x] == '?' && b != nil && y >= b.Region.Top && y < b.Region.Bottom && x >= b.Region.Left && x < b.Region.Right {
+				m[y][x] = true
+			}
+		}
+	}
+	return m
+}
+
+// Done tells whether every block is settled.
+func (s *SolverState) Done() bool {
+	return len(s.Blocks) == 0
+}
+
+// next returns up to n blocks to measure.
+func (s *SolverState) next(n int) []Block {
+	list := []Block{}
+	for _, b := range s.Blocks {
+		if len(list) == n {
+			break
+		}
+		if b.Ones < 0 && b.After == 0 {
+			list = append(list, b)
+		}
+	}
+	return list
+}
+
+// measure records that the candidate of b was answered wrong dots wrong.
+func (s *SolverState) measure(b Block, wrong int) {
+	known(s.Blocks, b.ID, (s.Wrong+s.Ones+s.count(b.Region)-wrong)/2)
+}
+
+// known records in blocks that block id holds ones ones.
+func known(blocks []Block, id, ones int) {
+	for i := range blocks {
+		switch {
+		case blocks[i].ID == id:
+			blocks[i].Ones = ones
+		case blocks[i].After == id:
+			blocks[i].Ones = blocks[i].Rest - ones
+			blocks[i].After = 0
+		}
+	}
+}
+
+// plan settles the blocks whose ones are known. The block strategy
+// settles a mixed block to its majority, the others split it in halves
+// and measure the first one only.
+func (s *SolverState) plan() {
+	for changed := true; changed; {
+		changed = false
+		blocks := s.Blocks
+		s.Blocks = make([]Block, 0, len(blocks))
+		for i, b := range blocks {
+			k := s.count(b.Region)
+			if b.Ones < 0 && b.After == 0 && k == 0 {
+				b.Ones = 0
+				known(blocks[i:], b.ID, 0)
+			}
+			if b.Ones < 0 {
+				s.Blocks = append(s.Blocks, b)
+				continue
+			}
+			changed = true
+			switch {
+			case b.Ones == 0:
+				s.settle(b.Region, '0')
+			case b.Ones == k:
+				s.settle(b.Region, '1')
+			case s.Strategy == "block":
+				if 2*b.Ones > k {
+					s.settle(b.Region, '1')
+					s.Wrong += k - b.Ones
+				} else {
+					s.settle(b.Region, '0')
+					s.Wrong += b.Ones
+				}
+			default:
+				first, second := b.Region, b.Region
+				if b.Region.Bottom-b.Region.Top > b.Region.Right-b.Region.Left {
+					first.Bottom = (b.Region.Top + b.Region.Bottom) / 2
+					second.Top = first.Bottom
+				} else {
+					first.Right = (b.Region.Left + b.Region.Right) / 2
+					second.Left = first.Right
+				}
+				id := s.add(first).ID
+				rest := s.add(second)
+				rest.After, rest.Rest = id, b.Ones
+				continue
+			}
+			s.Ones -= b.Ones
+		}
+	}
+}
+
+// answered records the responses to a request of candidates.
+func (s *SolverState) answered(candidates int, results []AnswerResponse) {
+	s.Requests++
+	s.Candidates += candidates
+	for _, r := range results {
+		if s.Best < 0 || r.Wrong < s.Best {
+			s.Best = r.Wrong
+		}
+		if r.Flag != "" {
+			s.Flag = r.Flag
+		}
+	}
+}
+
+// Client speaks the answer API of the server at Server.
+type Client struct {
+	Server string
+	// Encoding is text or json for one candidate per request, batch or
+	// multipart for several.
+	Encoding string
+	HTTP     *http.Client
+	// Wait paces the requests. When set it also replaces the Retry-After
+	// of rate limited requests.
+	Wait time.Duration
+	last time.Time
+	// Progress is told every hundred requests, nil for silence.
+	Progress io.Writer
+}
+
+// Questions lists the questions of the server.
+func (c *Client) Questions() ([]QuestionInfo, error) {
+	resp, err := c.HTTP.Get(c.Server + "/api/v1/questions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var infos []QuestionInfo
+	if err := decodeResponse(resp, &infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// Answer sends maps as candidates for question number, waiting as long as
+// the server asks when rate limited.
+func (c *Client) Answer(number int, maps []Map) ([]AnswerResponse, error) {
+	if len(maps) != 1 && (c.Encoding == "text" || c.Encoding == "json") {
+		return nil, fmt.Errorf("%s sends one candidate per request", c.Encoding)
+	}
+	batch := c.Encoding == "batch" || c.Encoding == "multipart"
+	url := c.Server + "/api/v1/answer/" + strconv.Itoa(number)
+	if batch {
+		url += "/batch"
+	}
+	body, contentType, err := encodeAnswer(c.Encoding, maps)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if c.Wait > 0 {
+			time.Sleep(time.Until(c.last.Add(c.Wait)))
+			c.last = time.Now()
+		}
+		resp, err := c.HTTP.Post(url, contentType, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if batch {
+			var br BatchResponse
+			err = decodeResponse(resp, &br)
+			resp.Body.Close()
+			if err == nil {
+				return br.Results, nil
+			}
+		} else {
+			var ar AnswerResponse
+			err = decodeResponse(resp, &ar)
+			resp.Body.Close()
+			if err == nil {
+				return []AnswerResponse{ar}, nil
+			}
+		}
+		if e, ok := err.(*APIError); !ok || e.Code != errRateLimited.Code {
+			return nil, err
+		}
+		if c.Wait == 0 {
+			wait, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			time.Sleep(time.Duration(max(wait, 1)) * time.Second)
+		}
+	}
+}
+
+// encodeAnswer returns the body and content type of maps in encoding.
+func encodeAnswer(encoding string, maps []Map) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch encoding {
+	case "text":
+		if err := gzipWrite(&buf, []byte(maps[0].Format("\n"))); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/plain", nil
+	case "json", "batch":
+		reqs := make(BatchRequest, len(maps))
+		for i, m := range maps {
+			reqs[i] = m.request()
+		}
+		var v interface{} = reqs
+		if encoding == "json" {
+			v = reqs[0]
+		}
+		js, err := json.Marshal(v)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := gzipWrite(&buf, js); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "application/json", nil
+	case "multipart":
+		mw := multipart.NewWriter(&buf)
+		for i, m := range maps {
+			part, err := mw.CreateFormFile("map", fmt.Sprintf("%d.txt.gz", i))
+			if err != nil {
+				return nil, "", err
+			}
+			if err := gzipWrite(part, []byte(m.Format("\n"))); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), mw.FormDataContentType(), nil
+	}
+	return nil, "", fmt.Errorf("unknown encoding %q", encoding)
+}
+
+func gzipWrite(w io.Writer, p []byte) error {
+	gw := gzip.NewWriter(w)
+	if _, err := gw.Write(p); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// request is the inverse of AnswerRequest.toMap.
+func (m Map) request() AnswerRequest {
+	req := AnswerRequest{Map: make([][]int, len(m))}
+	for y, row := range m {
+		req.Map[y] = make([]int, len(row))
+		for x, dot := range row {
+			if dot {
+				req.Map[y][x] = 1
+			}
+		}
+	}
+	return req
+}
+
+// decodeResponse reads a successful response into v and an error
+// response into an *APIError.
+func decodeResponse(resp *http.Response, v interface{}) error {
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var er ErrorResponse
+		if err := json.Unmarshal(buf, &er); err != nil || er.Error == nil {
+			return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(buf))
+		}
+		er.Error.Status = resp.StatusCode
+		return er.Error
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// solve runs s against the server until it is done or limit requests
+// were sent, 0 for no limit. save is called after every response.
+func (c *Client) solve(s *SolverState, batch, limit int, save func() error) error {
+	if c.Encoding == "text" || c.Encoding == "json" {
+		batch = 1
+	}
+	for sent := 0; limit == 0 || sent < limit; sent++ {
+		if s.Ones < 0 {
+			results, err := c.Answer(s.Number, []Map{s.candidate(nil)})
+			if err != nil {
+				return err
+			}
+			s.Ones = results[0].Wrong
+			s.answered(1, results)
+			s.plan()
+		} else {
+			blocks := s.next(batch)
+			if len(blocks) == 0 {
+				break
+			}
+			maps := make([]Map, len(blocks))
+			for i := range blocks {
+				maps[i] = s.candidate(&blocks[i])
+			}
+			results, err := c.Answer(s.Number, maps)
+			if err != nil {
+				return err
+			}
+			for i, b := range blocks {
+				s.measure(b, results[i].Wrong)
+			}
+			s.answered(len(maps), results)
+			s.plan()
+		}
+		if err := save(); err != nil {
+			return err
+		}
+		if c.Progress != nil && s.Requests%100 == 0 {
+			fmt.Fprintf(c.Progress, "question %d: %d requests, %d blocks left\n", s.Number, s.Requests, len(s.Blocks))
+		}
+	}
+	if !s.Done() || s.Best == s.Wrong {
+		return nil
+	}
+	// the last measures settled dots no candidate held yet
+	results, err := c.Answer(s.Number, []Map{s.candidate(nil)})
+	if err != nil {
+		return err
+	}
+	s.answered(1, results)
+	return save()
+}
+
+// cmdClient solves the questions of a running server with one of the
+// built-in strategies and reports what it took.
+func cmdClient(args []string) int {
+	fs := flag.NewFlagSet("client", flag.ExitOnError)
+	server := fs.String("server", "http://localhost:8080", "URL of the server")
+	encoding := fs.String("encoding", "json", "text, json, batch or multipart")
+	strategy := fs.String("strategy", "adaptive", "pixel, block or adaptive")
+	size := fs.Int("block", 8, "side of the blocks of the block and adaptive strategies")
+	statePath := fs.String("state", "client_state.json", "file to resume from and save the progress to")
+	limit := fs.Int("max", 0, "requests per question, 0 for no limit")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: client [flags] [NUMBER...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *strategy != "pixel" && *strategy != "block" && *strategy != "adaptive" || *size < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	c := &Client{Server: strings.TrimSuffix(*server, "/"), Encoding: *encoding, HTTP: &http.Client{Timeout: time.Minute}, Progress: os.Stderr}
+	if _, _, err := encodeAnswer(c.Encoding, []Map{{}}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	infos, err := c.Questions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	numbers := map[int]bool{}
+	for _, arg := range fs.Args() {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(infos) {
+			fmt.Fprintf(os.Stderr, "unknown question %s\n", arg)
+			return 2
+		}
+		numbers[n] = true
+	}
+
+	states := map[int]*SolverState{}
+	if buf, err := ioutil.ReadFile(*statePath); err == nil {
+		if err := json.Unmarshal(buf, &states); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", *statePath, err)
+			return 1
+		}
+	}
+	save := func() error {
+		buf, err := json.Marshal(states)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(*statePath, buf, 0644)
+	}
+
+	status := 0
+	for _, info := range infos {
+		if len(numbers) > 0 && !numbers[info.Number] || len(numbers) == 0 && info.Status != "open" {
+			continue
+		}
+		s := states[info.Number]
+		if s == nil || s.Strategy != *strategy || s.Size != *size && *strategy != "pixel" || len(s.Dots) != info.Height {
+			s = newSolverState(info, *strategy, *size)
+			states[info.Number] = s
+		}
+		start, requests := time.Now(), s.Requests
+		if err := c.solve(s, info.Batch, *limit, save); err != nil {
+			fmt.Fprintf(os.Stderr, "question %d: %s\n", info.Number, err)
+			status = 1
+		}
+		fmt.Printf("question %d: %d requests (%d this run, %s), %d candidates, best %d wrong, %d blocks left",
+			info.Number, s.Requests, s.Requests-requests, time.Since(start).Round(time.Second), s.Candidates, s.Best, len(s.Blocks))
+		if s.Flag != "" {
+			fmt.Printf(", flag %s", s.Flag)
+		}
+		fmt.Println()
+	}
+	return status
+}
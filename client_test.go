@@ -0,0 +1,56 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientSolve(t *testing.T) {
+	hidden := "0000111100 0001111110 0011000110 0000001100 0000111000 0001100000 0011111110 0000000000"
+	q, err := NewQuestion(QuestionConfig{Map: hidden, Flag: "SECCON{x}", Batch: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited {
+			limited = false
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: errRateLimited})
+			return
+		}
+		gz, _ := gzip.NewReader(r.Body)
+		var req BatchRequest
+		json.NewDecoder(gz).Decode(&req)
+		resp := BatchResponse{}
+		for _, a := range req {
+			score, wrong, flag, _ := q.Try(a.toMap())
+			resp.Results = append(resp.Results, AnswerResponse{Wrong: wrong, Score: score, Flag: flag})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	c := &Client{Server: srv.URL, Encoding: "batch", HTTP: srv.Client()}
+	info := QuestionInfo{Number: 1, Width: 10, Height: 8, Batch: 4}
+	for _, strategy := range []string{"pixel", "block", "adaptive"} {
+		s := newSolverState(info, strategy, 4)
+		if err := c.solve(s, info.Batch, 0, func() error { return nil }); err != nil {
+			t.Fatal(strategy, err)
+		}
+		if !s.Done() || s.Best != s.Wrong {
+			t.Errorf("%s: done %v, best %d, %d wrong settled", strategy, s.Done(), s.Best, s.Wrong)
+		}
+		if strategy == "block" {
+			continue
+		}
+		if strings.Join(s.Dots, " ") != hidden || s.Flag == "" {
+			t.Errorf("%s: solved %q, flag %q", strategy, strings.Join(s.Dots, " "), s.Flag)
+		}
+	}
+}
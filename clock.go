@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time of the game. The server runs on the real clock,
+// dry runs on a clock shifted and sped up by -time-offset and -time-scale,
+// and tests on a FakeClock moved by hand.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// scaledClock runs scale times faster than real time from origin, offset
+// ahead of it.
+type scaledClock struct {
+	origin time.Time
+	offset time.Duration
+	scale  float64
+}
+
+// newClock returns a clock offset ahead of real time and running scale
+// times faster from now on, the real clock for 1 and 0.
+func newClock(scale float64, offset time.Duration) Clock {
+	if scale == 1 && offset == 0 {
+		return realClock{}
+	}
+	return scaledClock{origin: time.Now(), offset: offset, scale: scale}
+}
+
+func (c scaledClock) Now() time.Time {
+	elapsed := time.Duration(float64(time.Since(c.origin)) * c.scale)
+	return c.origin.Add(c.offset + elapsed)
+}
+
+// realDuration converts d of game time into real time.
+func realDuration(d time.Duration) time.Duration {
+	if c, ok := clock.(scaledClock); ok {
+		return time.Duration(float64(d) / c.scale)
+	}
+	return d
+}
+
+// FakeClock stands still until it is set or advanced.
+type FakeClock struct {
+	now time.Time
+	mu  *sync.Mutex
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, mu: &sync.Mutex{}}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Add moves the clock d ahead.
+func (c *FakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
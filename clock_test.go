@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalBreakerClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	setTeams([]TeamConfig{{Name: "a", Address: "192.168.1."}})
+	i := NewIntervalBreaker(clock, 10*time.Second)
+	if !i.Check("192.168.1.1") {
+		t.Error("the first request must pass")
+	}
+	clock.Add(5 * time.Second)
+	if i.Check("192.168.1.1") {
+		t.Error("a request within the interval must be limited")
+	}
+	if i.Ready("a") {
+		t.Error("a limited team must not be ready")
+	}
+	clock.Add(10 * time.Second)
+	if !i.Check("192.168.1.1") {
+		t.Error("a request after the interval must pass")
+	}
+	if next := i.Next("a"); !next.Equal(clock.Now().Add(10 * time.Second)) {
+		t.Errorf("next request at %v", next)
+	}
+}
+
+func TestScaledClock(t *testing.T) {
+	if _, ok := newClock(1, 0).(realClock); !ok {
+		t.Error("no scale nor offset must give the real clock")
+	}
+	c := newClock(1000, time.Hour)
+	before := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if d := c.Now().Sub(before); d < time.Hour+10*time.Second || d > time.Hour+time.Minute {
+		t.Errorf("clock is %v ahead", d)
+	}
+}
+
+func TestRecordsClock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	logger, _ = newLogger("error", "stderr")
+	fake := NewFakeClock(time.Date(2016, 1, 31, 11, 0, 0, 0, time.UTC))
+	clock = fake
+	defer func() { clock = realClock{} }()
+
+	al := NewAuditLog("audit.log")
+	al.Record("10.0.0.1", "pause", "", "", "")
+	s := NewStats()
+	s.Request("a")
+	fi := NewFlagIssuer("")
+	fi.Issue("a", 0, "SECCON{1}")
+	if !al.List()[0].Time.Equal(fake.Now()) || !s.Get("a", 1).LastRequest.Equal(fake.Now()) || !fi.List()[0].Time.Equal(fake.Now()) {
+		t.Error("records must take the time of the game clock")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type dashboardData struct {
+	Login     bool
+	Paused    bool
+	Now       time.Time
+	Questions []dashboardQuestion
+	Teams     []dashboardTeam
+}
+
+type dashboardQuestion struct {
+	QuestionInfo
+	Solved    int
+	SolveRate float64
+}
+
+type dashboardTeam struct {
+	Name  string
+	Stats TeamStats
+	Score []int
+	Total int
+	Flags int
+	// Banned holds the reason of the ban.
+	Banned string
+}
+
+// viewDashboard shows organizers what every team and question is up to.
+// The token is taken from the admin_token cookie set by the login form.
+func viewDashboard(c *gin.Context) {
+	if !adminAuthorized(c) {
+		c.HTML(http.StatusUnauthorized, "admin.html", dashboardData{Login: true})
+		return
+	}
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	now := clock.Now()
+	_, paused := game.Overrides()
+	data := dashboardData{
+		Paused: paused,
+		Now:    now,
+	}
+
+	items := map[string]RankingItem{}
+	for _, item := range ranking.Snapshot() {
+		items[item.Name] = item
+	}
+	names := map[string]bool{}
+	for _, name := range teamNames() {
+		names[name] = true
+	}
+	for _, name := range stats.Names() {
+		names[name] = true
+	}
+	for name := range items {
+		names[name] = true
+	}
+	flags := issuer.List()
+	banned := bans.List()
+	infos := game.Info(now)
+
+	for name := range names {
+		t := dashboardTeam{
+			Name:   name,
+			Stats:  stats.Get(name, len(infos)),
+			Score:  items[name].Score,
+			Total:  items[name].TotalScore,
+			Banned: banned[name],
+		}
+		for _, f := range flags {
+			if f.Team == name {
+				t.Flags++
+			}
+		}
+		data.Teams = append(data.Teams, t)
+	}
+	sort.Slice(data.Teams, func(i, j int) bool {
+		return data.Teams[i].Name < data.Teams[j].Name
+	})
+
+	for i, info := range infos {
+		q := dashboardQuestion{QuestionInfo: info}
+		for _, f := range flags {
+			if f.Question == i {
+				q.Solved++
+			}
+		}
+		if len(names) > 0 {
+			q.SolveRate = float64(q.Solved) * 100 / float64(len(names))
+		}
+		data.Questions = append(data.Questions, q)
+	}
+	c.HTML(http.StatusOK, "admin.html", data)
+}
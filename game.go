@@ -1,60 +1,144 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Map [][]bool
 
+var (
+	ErrInvalidSize     = errors.New("invalid image size")
+	ErrNotOpen         = errors.New("question is not open")
+	ErrUnknownQuestion = errors.New("unknown question")
+	ErrPaused          = errors.New("question is paused")
+	ErrNotStarted      = errors.New("game has not started yet")
+)
+
 type Game struct {
 	list  []Question
 	start time.Time
+	end   time.Time
+	// released remembers the hints already logged as released.
+	released map[[2]int]bool
+	// override replaces the schedule of a question with "open", "closed"
+	// or "paused" until it is cleared.
+	override map[int]string
+	paused   bool
+	// rounds are in order. forced is the round started by hand, if any,
+	// and seen the current round as last reported by AdvanceRound.
+	rounds []Round
+	forced string
+	seen   string
+	clock  Clock
+	mu     *sync.Mutex
 }
 
 type Question struct {
 	hMap     Map
 	openTime time.Duration
-	flag     string
+	// closeTime is zero when the question never closes.
+	closeTime time.Duration
+	flag      string
+	// threshold is the ratio of wrong dots below which the flag is given.
+	threshold float64
+	// batchMax is the number of candidates accepted in one batch and
+	// batchCost what each of them is charged to the rate limiter.
+	batchMax  int
+	batchCost float64
+	hints     []Hint
+}
+
+// Hint is released to players once after has passed from the start of
+// the game. It may reveal a region of the hidden image.
+type Hint struct {
+	text   string
+	after  time.Duration
+	region *HintRegion
 }
 
-func NewQuestion(strMap string, flag string, openTime int) (Question, error) {
-	hMap, err := parseMapString(strMap, " ")
+// HintRegion is the rectangle of rows [Top, Bottom) and columns
+// [Left, Right).
+type HintRegion struct {
+	Top    int `json:"top"`
+	Left   int `json:"left"`
+	Bottom int `json:"bottom"`
+	Right  int `json:"right"`
+}
+
+func NewQuestion(qc QuestionConfig) (Question, error) {
+	if qc.Map == "" {
+		return Question{}, fmt.Errorf("%w: empty map", ErrInvalidSize)
+	}
+	hMap, err := parseMapString(qc.Map, " ")
 	if err != nil {
-		return Question{}, nil
+		return Question{}, err
 	}
 
-	return Question{
-		hMap:     hMap,
-		openTime: time.Duration(openTime) * time.Second,
-		flag:     flag,
-	}, nil
+	q := Question{
+		hMap:      hMap,
+		openTime:  time.Duration(qc.Open) * time.Second,
+		closeTime: time.Duration(qc.Close) * time.Second,
+		flag:      qc.Flag,
+		threshold: qc.Threshold,
+		batchMax:  qc.Batch,
+		batchCost: qc.BatchCost,
+	}
+	if q.threshold <= 0 {
+		q.threshold = 0.1
+	}
+	for _, hc := range qc.Hints {
+		q.hints = append(q.hints, Hint{
+			text:   hc.Text,
+			after:  time.Duration(hc.After) * time.Second,
+			region: q.clip(hc.Region),
+		})
+	}
+	if q.batchMax <= 0 {
+		q.batchMax = 1
+	}
+	if q.batchCost <= 0 {
+		q.batchCost = 1
+	}
+	return q, nil
 }
 
 func (q Question) IsOpen(start, when time.Time) bool {
+	if q.closeTime != 0 && !start.Add(q.closeTime).After(when) {
+		return false
+	}
 	if start.Add(q.openTime).Before(when) {
 		return true
 	}
 	return false
 }
 
+// Status tells whether the question is "upcoming", "open" or "closed".
+func (q Question) Status(start, when time.Time) string {
+	if q.IsOpen(start, when) {
+		return "open"
+	}
+	if q.closeTime != 0 && !start.Add(q.closeTime).After(when) {
+		return "closed"
+	}
+	return "upcoming"
+}
+
 func (q Question) Try(answer Map) (int, int, string, error) {
 	worngs := 0
 	if len(q.hMap) != len(answer) || len(answer) == 0 {
-		return 0, 0, "", fmt.Errorf("invalid image size")
-	}
-	if len(q.hMap[0]) != len(answer[0]) {
-		return 0, 0, "", fmt.Errorf("invalid image size")
+		return 0, 0, "", ErrInvalidSize
 	}
 	for i := range answer {
+		if len(q.hMap[i]) != len(answer[i]) {
+			return 0, 0, "", ErrInvalidSize
+		}
 		for j := range answer[i] {
-			if len(q.hMap) <= i {
-				return 0, 0, "", fmt.Errorf("invalid image size")
-			}
-			if len(q.hMap[i]) <= j {
-				return 0, 0, "", fmt.Errorf("invalid image size")
-			}
 			if answer[i][j] != q.hMap[i][j] {
 				worngs += 1
 			}
@@ -68,57 +152,335 @@ func (q Question) Try(answer Map) (int, int, string, error) {
 	return height*width - worngs, worngs, "", nil
 }
 
+// Size returns the dimensions of the hidden image.
+func (q Question) Size() (width, height int) {
+	if len(q.hMap) == 0 {
+		return 0, 0
+	}
+	return len(q.hMap[0]), len(q.hMap)
+}
+
+// clip fits r into the image, nil is returned when nothing is left.
+func (q Question) clip(r *HintRegion) *HintRegion {
+	if r == nil {
+		return nil
+	}
+	width, height := q.Size()
+	c := *r
+	if c.Top < 0 {
+		c.Top = 0
+	}
+	if c.Left < 0 {
+		c.Left = 0
+	}
+	if c.Bottom > height {
+		c.Bottom = height
+	}
+	if c.Right > width {
+		c.Right = width
+	}
+	if c.Top >= c.Bottom || c.Left >= c.Right {
+		return nil
+	}
+	return &c
+}
+
+// Hints returns the hints released by when.
+func (q Question) Hints(start, when time.Time) []HintInfo {
+	list := []HintInfo{}
+	for _, h := range q.hints {
+		if start.Add(h.after).After(when) {
+			continue
+		}
+		info := HintInfo{Text: h.text, Region: h.region}
+		if h.region != nil {
+			for _, row := range q.hMap[h.region.Top:h.region.Bottom] {
+				line := make([]byte, 0, h.region.Right-h.region.Left)
+				for _, dot := range row[h.region.Left:h.region.Right] {
+					if dot {
+						line = append(line, '1')
+					} else {
+						line = append(line, '0')
+					}
+				}
+				info.Map = append(info.Map, string(line))
+			}
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
 func (q Question) CanGetFlag(worngs int) bool {
 	height := len(q.hMap)
 	width := len(q.hMap[0])
-	if float64(worngs)/float64(height*width) < 0.1 {
+	if float64(worngs)/float64(height*width) < q.threshold {
 		return true
 	}
 	return false
 }
 
-func NewGame(start time.Time, questions []QuestionConfig) (*Game, error) {
+// NewGame creates a game running from start to end. Questions without a
+// close time of their own close at the end of the game, if it is set.
+func NewGame(clock Clock, start, end time.Time, questions []QuestionConfig, rounds []RoundConfig) (*Game, error) {
 	g := &Game{
-		list:  []Question{},
-		start: start,
+		clock:    clock,
+		list:     []Question{},
+		start:    start,
+		end:      end,
+		released: make(map[[2]int]bool),
+		override: make(map[int]string),
+		mu:       &sync.Mutex{},
 	}
 	for _, m := range questions {
-		q, err := NewQuestion(m.Map, m.Flag, m.Open)
+		q, err := NewQuestion(m)
 		if err != nil {
 			return nil, err
 		}
+		if q.closeTime == 0 && !end.IsZero() {
+			q.closeTime = end.Sub(start)
+		}
 		g.list = append(g.list, q)
 	}
+	g.rounds = newRounds(rounds, start, end)
+	g.seen = g.currentRound(clock.Now())
 	return g, nil
 }
 
+// Period returns when the game starts and ends, end is zero when the game
+// has no end.
+func (g *Game) Period() (start, end time.Time) {
+	return g.start, g.end
+}
+
+// QuestionInfo is what players may know about a question.
+type QuestionInfo struct {
+	Number    int        `json:"number"`
+	Width     int        `json:"width"`
+	Height    int        `json:"height"`
+	Open      time.Time  `json:"open"`
+	Close     *time.Time `json:"close,omitempty"`
+	Status    string     `json:"status"`
+	Threshold float64    `json:"threshold"`
+	// Batch is the number of candidates accepted in one batch.
+	Batch int        `json:"batch"`
+	Hints []HintInfo `json:"hints"`
+}
+
+type HintInfo struct {
+	Text   string      `json:"text"`
+	Region *HintRegion `json:"region,omitempty"`
+	// Map is the revealed region, one string of 0 and 1 per row.
+	Map []string `json:"map,omitempty"`
+}
+
+// Info describes every question as of now.
+func (g *Game) Info(now time.Time) []QuestionInfo {
+	list := make([]QuestionInfo, 0, len(g.list))
+	for i, q := range g.list {
+		width, height := q.Size()
+		info := QuestionInfo{
+			Number:    i + 1,
+			Width:     width,
+			Height:    height,
+			Open:      g.start.Add(q.openTime),
+			Status:    g.status(i, now),
+			Threshold: q.threshold,
+			Batch:     q.batchMax,
+			Hints:     q.Hints(g.start, now),
+		}
+		if q.closeTime != 0 {
+			close := g.start.Add(q.closeTime)
+			info.Close = &close
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
+func (g *Game) Question(number int) (Question, error) {
+	if number < 0 || number >= len(g.list) {
+		return Question{}, ErrUnknownQuestion
+	}
+	return g.list[number], nil
+}
+
+// MaxSize returns the dimensions of the largest configured question.
+func (g *Game) MaxSize() (width, height int) {
+	for _, q := range g.list {
+		w, h := q.Size()
+		if w > width {
+			width = w
+		}
+		if h > height {
+			height = h
+		}
+	}
+	return width, height
+}
+
+// ReleaseHints logs the hints released since the last call.
+func (g *Game) ReleaseHints(now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, q := range g.list {
+		for j, h := range q.hints {
+			if g.released[[2]int{i, j}] || g.start.Add(h.after).After(now) {
+				continue
+			}
+			g.released[[2]int{i, j}] = true
+			logger.Info("hint released", "question", i+1, "hint", j+1, "text", h.text)
+		}
+	}
+}
+
+// inherit takes over what organizers and time changed on old, so that a
+// reloaded game goes on where old stopped.
+func (g *Game) inherit(old *Game) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k, v := range old.released {
+		g.released[k] = v
+	}
+	for k, v := range old.override {
+		g.override[k] = v
+	}
+	g.paused = old.paused
+	g.forced = old.forced
+	g.seen = old.seen
+}
+
+// SetOverride forces question number to state, one of "open", "closed"
+// or "paused". An empty state returns the question to its schedule.
+func (g *Game) SetOverride(number int, state string) error {
+	if number < 0 || number >= len(g.list) {
+		return ErrUnknownQuestion
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch state {
+	case "":
+		delete(g.override, number)
+	case "open", "closed", "paused":
+		g.override[number] = state
+	default:
+		return fmt.Errorf("unknown state %q", state)
+	}
+	return nil
+}
+
+// Pause stops or resumes every question at once.
+func (g *Game) Pause(paused bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = paused
+}
+
+// Overrides returns the questions forced out of their schedule and whether
+// the game is paused.
+func (g *Game) Overrides() (map[int]string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m := make(map[int]string, len(g.override))
+	for k, v := range g.override {
+		m[k+1] = v
+	}
+	return m, g.paused
+}
+
+func (g *Game) status(number int, now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return "paused"
+	}
+	if state, ok := g.override[number]; ok {
+		return state
+	}
+	if state := g.roundStatus(number, now); state != "" {
+		return state
+	}
+	return g.list[number].Status(g.start, now)
+}
+
 func (g *Game) IsOpen(number int) bool {
 	if number < 0 ||
 		number >= len(g.list) {
 		return false
 	}
-	if !g.list[number].IsOpen(g.start, time.Now()) {
+	if g.status(number, g.clock.Now()) != "open" {
 		return false
 	}
 	return true
 }
 
-func (g *Game) Try(answer Map, number int) (int, int, string, error) {
+// CheckOpen tells why question number does not take answers now, nil
+// when it does.
+func (g *Game) CheckOpen(number int) error {
+	if number < 0 || number >= len(g.list) {
+		return ErrUnknownQuestion
+	}
 	if !g.IsOpen(number) {
-		return 0, 0x8fffffff, "", fmt.Errorf("invalid number")
+		now := g.clock.Now()
+		if g.status(number, now) == "paused" {
+			return ErrPaused
+		}
+		if now.Before(g.start) {
+			return ErrNotStarted
+		}
+		return ErrNotOpen
 	}
+	return nil
+}
+
+func (g *Game) Try(answer Map, number int) (int, int, string, error) {
+	if err := g.CheckOpen(number); err != nil {
+		return 0, 0, "", err
+	}
+	defer observe(metricTry, time.Now())
 	return g.list[number].Try(answer)
 }
 
+// checkSize fails with ErrInvalidSize unless m has height rows of width
+// dots each.
+func (m Map) checkSize(width, height int) error {
+	if len(m) != height {
+		return fmt.Errorf("%w: %d lines expected but got %d", ErrInvalidSize, height, len(m))
+	}
+	for i, row := range m {
+		if len(row) != width {
+			return fmt.Errorf("%w: %d dots expected but got %d at line %d", ErrInvalidSize, width, len(row), i+1)
+		}
+	}
+	return nil
+}
+
+// Format is the inverse of parseMapString.
+func (m Map) Format(sep string) string {
+	lines := make([]string, len(m))
+	for i, row := range m {
+		line := make([]byte, len(row))
+		for j, dot := range row {
+			line[j] = '0'
+			if dot {
+				line[j] = '1'
+			}
+		}
+		lines[i] = string(line)
+	}
+	return strings.Join(lines, sep)
+}
+
 func parseMapString(m string, sep string) (Map, error) {
 	lines := strings.Split(m, sep)
 	width := 0
-	println(len(lines), len(lines[0]))
 
 	newMap := make(Map, 0)
 	for lNumber, line := range lines {
 		if width != len(line) && width != 0 {
-			return nil, fmt.Errorf("%d charactors expected but got %d at line %d", width, len(line), lNumber)
+			return nil, fmt.Errorf("%d characters expected but got %d at line %d", width, len(line), lNumber)
 		}
 		width = len(line)
 		l := make([]bool, width)
@@ -129,3 +491,41 @@ func parseMapString(m string, sep string) (Map, error) {
 	}
 	return newMap, nil
 }
+
+// parseMapStream reads a width * height image line by line and gives up
+// on the first line that does not fit.
+func parseMapStream(r io.Reader, width, height int) (Map, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, width+2), width+2)
+
+	newMap := make(Map, 0, height)
+	lNumber := 0
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if len(line) == 0 && len(newMap) == height {
+			continue
+		}
+		if len(newMap) == height {
+			return nil, fmt.Errorf("%w: %d lines expected", ErrInvalidSize, height)
+		}
+		if len(line) != width {
+			return nil, fmt.Errorf("%w: %d characters expected but got %d at line %d", ErrInvalidSize, width, len(line), lNumber)
+		}
+		l := make([]bool, width)
+		for i := 0; i < width; i++ {
+			l[i] = (line[i] != '0')
+		}
+		newMap = append(newMap, l)
+		lNumber++
+	}
+	if err := sc.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return nil, fmt.Errorf("%w: line %d is too long", ErrInvalidSize, lNumber)
+		}
+		return nil, err
+	}
+	if len(newMap) != height {
+		return nil, fmt.Errorf("%w: %d lines expected but got %d", ErrInvalidSize, height, len(newMap))
+	}
+	return newMap, nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -18,3 +20,112 @@ func TestQuestionIsOpen(t *testing.T) {
 	}
 
 }
+
+func TestQuestionStatus(t *testing.T) {
+	start := time.Now()
+	q := &Question{
+		hMap:      Map{},
+		openTime:  30 * time.Second,
+		closeTime: 60 * time.Second,
+	}
+	for _, c := range []struct {
+		after time.Duration
+		want  string
+	}{
+		{10 * time.Second, "upcoming"},
+		{40 * time.Second, "open"},
+		{70 * time.Second, "closed"},
+	} {
+		if got := q.Status(start, start.Add(c.after)); got != c.want {
+			t.Errorf("after %v: got %s, want %s", c.after, got, c.want)
+		}
+	}
+}
+
+func TestParseMapStream(t *testing.T) {
+	m, err := parseMapStream(strings.NewReader("010\r\n111\r\n"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m[0][0] || !m[0][1] || !m[1][2] {
+		t.Errorf("unexpected map: %v", m)
+	}
+
+	for _, in := range []string{
+		"010\r\n11\r\n",
+		"010\r\n111\r\n000\r\n",
+		"010\r\n",
+		"0101010101\r\n111\r\n",
+	} {
+		if _, err := parseMapStream(strings.NewReader(in), 3, 2); !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("%q: got %v, want ErrInvalidSize", in, err)
+		}
+	}
+}
+
+func TestQuestionHints(t *testing.T) {
+	start := time.Now()
+	q, _ := NewQuestion(QuestionConfig{
+		Map: "0110 1001 1001 0110",
+		Hints: []HintConfig{
+			{Text: "first", After: 0},
+			{Text: "second", After: 60, Region: &HintRegion{Top: 1, Left: 0, Bottom: 3, Right: 10}},
+		},
+	})
+
+	if hints := q.Hints(start, start.Add(time.Second)); len(hints) != 1 || hints[0].Text != "first" {
+		t.Errorf("unexpected hints: %v", hints)
+	}
+	hints := q.Hints(start, start.Add(2*time.Minute))
+	if len(hints) != 2 {
+		t.Fatalf("unexpected hints: %v", hints)
+	}
+	if r := hints[1].Region; r == nil || r.Right != 4 {
+		t.Errorf("region must be clipped to the image: %v", r)
+	}
+	if m := hints[1].Map; len(m) != 2 || m[0] != "1001" {
+		t.Errorf("unexpected revealed region: %v", m)
+	}
+}
+
+func TestGameClock(t *testing.T) {
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start.Add(-time.Minute))
+	g, err := NewGame(clock, start, start.Add(time.Hour), []QuestionConfig{{Map: "01 10", Open: 60}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer := Map{{false, true}, {true, false}}
+	if _, _, _, err := g.Try(answer, 0); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("before the start: got %v, want ErrNotStarted", err)
+	}
+	clock.Add(90 * time.Second)
+	if _, _, _, err := g.Try(answer, 0); !errors.Is(err, ErrNotOpen) {
+		t.Errorf("before the opening: got %v, want ErrNotOpen", err)
+	}
+	clock.Add(time.Minute)
+	if !g.IsOpen(0) {
+		t.Error("question 1 must be open")
+	}
+	if score, wrong, _, err := g.Try(answer, 0); err != nil || score != 4 || wrong != 0 {
+		t.Errorf("got score %d, %d wrong, %v", score, wrong, err)
+	}
+	clock.Set(start.Add(2 * time.Hour))
+	if g.IsOpen(0) {
+		t.Error("question 1 must close at the end of the game")
+	}
+}
+
+func TestQuestionTryRagged(t *testing.T) {
+	q, _ := NewQuestion(QuestionConfig{Map: "0110 1001 1001 0110"})
+	row := []bool{false, true, true, false}
+	for _, m := range []Map{
+		{row, {}, {}, {}},
+		{row, row, row, row[:3]},
+		{row, row, row},
+	} {
+		if _, _, _, err := q.Try(m); !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("%v: got %v, want ErrInvalidSize", m, err)
+		}
+	}
+}
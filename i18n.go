@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// messages holds the texts shown to players per language and key. The
+// error.<code> texts must start the same as the APIError messages in
+// English.
+var messages = map[string]map[string]string{
+	"en": {
+		"lang.name": "English",
+
+		"title":       "Find the Image!",
+		"not_started": "The game has not started yet.",
+		"starts_in":   "It starts in",
+		"game_over":   "The game is over.",
+		"ends_in":     "The game ends in",
+		"in":          "in",
+		"example":     "example:",
+
+		"ranking":     "Ranking",
+		"rank":        "Rank",
+		"name":        "Name",
+		"score":       "SCORE",
+		"total":       "total",
+		"image":       "image%d",
+		"leader_hint": "SLA: staying 1st",
+
+		"round":            "Round",
+		"round.multiplier": "scores count %v times",
+
+		"status.upcoming": "not open yet",
+		"status.open":     "open",
+		"status.closed":   "closed",
+		"status.paused":   "paused",
+
+		"about":         "About this game",
+		"rule.images":   "There are %d hidden images.",
+		"rule.find":     "Please find all complete images.",
+		"rule.binary":   "Each dot is black or white (binary image).",
+		"rule.wrong":    "The server returns the count of dots that differ from the candidate image you send.",
+		"rule.interval": "You can send only 1 request per %v sec.",
+		"rule.sla":      "You get SLA points while you stay 1st.",
+		"rule.flag":     "The server gives you a flag when the ratio of wrong dots is below the threshold of the image.",
+
+		"col.image":      "Image",
+		"col.size":       "Size",
+		"col.open":       "Open",
+		"col.close":      "Close",
+		"col.threshold":  "Threshold",
+		"col.status":     "Status",
+		"col.score":      "Score",
+		"col.best_wrong": "Best wrong",
+		"col.attempts":   "Attempts",
+		"col.flag":       "Flag",
+
+		"questions.json": "The same list is available as JSON at GET /api/questions.",
+		"api.number":     "image number",
+		"api.request":    "Request Body",
+		"api.body":       "The request body is your candidate image.",
+		"api.gzip":       "Please compress it with gzip.",
+		"api.response":   "Response",
+		"api.wrong":      `"wrong" is the count of wrong dots.`,
+		"api.score":      `"score" is the count of correct dots.`,
+		"api.flag":       `"flag" is a flag for the attack point.`,
+
+		"hint":        "Hint",
+		"hint.region": "(rows %d-%d, columns %d-%d)",
+
+		"me.banned":       "Your team is banned.",
+		"me.rank":         "Rank",
+		"me.leader":       "(you are staying 1st and get SLA points)",
+		"me.behind":       "(%d behind 1st)",
+		"me.total":        "Total score",
+		"me.next_request": "Next request allowed at",
+		"me.rate_limited": "Requests turned down by the rate limit",
+		"me.json":         "The same data is available as JSON at GET /api/me.",
+
+		"teamflag.unknown":   "unknown team.",
+		"teamflag.banned":    "your team is banned.",
+		"teamflag.not_first": "your team is not in first place.",
+
+		"error.invalid_size":        "invalid image size",
+		"error.not_open":            "question is not open",
+		"error.rate_limited":        "request is too many",
+		"error.bad_encoding":        "request body is not a gzipped image",
+		"error.unknown_question":    "unknown question",
+		"error.too_large":           "request body is too large",
+		"error.paused":              "question is paused",
+		"error.banned":              "your team is banned",
+		"error.not_started":         "game has not started yet",
+		"error.too_many_candidates": "too many candidates",
+		"error.unknown_team":        "your address belongs to no team",
+		"error.unknown_round":       "unknown round",
+	},
+	"ja": {
+		"lang.name": "日本語",
+
+		"title":       "画像を探せ！",
+		"not_started": "ゲームはまだ始まっていません。",
+		"starts_in":   "開始まで",
+		"game_over":   "ゲームは終了しました。",
+		"ends_in":     "終了まで",
+		"in":          "あと",
+		"example":     "例:",
+
+		"ranking":     "ランキング",
+		"rank":        "順位",
+		"name":        "チーム",
+		"score":       "スコア",
+		"total":       "合計",
+		"image":       "画像%d",
+		"leader_hint": "SLA: 1位を維持中",
+
+		"round":            "ラウンド",
+		"round.multiplier": "得点は%v倍",
+
+		"status.upcoming": "公開前",
+		"status.open":     "公開中",
+		"status.closed":   "終了",
+		"status.paused":   "一時停止中",
+
+		"about":         "ゲームについて",
+		"rule.images":   "隠された画像が%d枚あります。",
+		"rule.find":     "すべての画像を完全に当ててください。",
+		"rule.binary":   "各ドットは黒か白です（2値画像）。",
+		"rule.wrong":    "送った候補画像と異なるドットの数をサーバが返します。",
+		"rule.interval": "リクエストは%v秒に1回までです。",
+		"rule.sla":      "1位を維持している間、SLAポイントが得られます。",
+		"rule.flag":     "誤ったドットの割合が画像のしきい値を下回るとフラグが得られます。",
+
+		"col.image":      "画像",
+		"col.size":       "サイズ",
+		"col.open":       "公開",
+		"col.close":      "終了",
+		"col.threshold":  "しきい値",
+		"col.status":     "状態",
+		"col.score":      "スコア",
+		"col.best_wrong": "最少の誤り",
+		"col.attempts":   "送信数",
+		"col.flag":       "フラグ",
+
+		"questions.json": "同じ一覧を GET /api/questions で JSON として取得できます。",
+		"api.number":     "画像番号",
+		"api.request":    "リクエストボディ",
+		"api.body":       "リクエストボディは候補画像です。",
+		"api.gzip":       "gzip で圧縮してください。",
+		"api.response":   "レスポンス",
+		"api.wrong":      `"wrong" は誤ったドットの数です。`,
+		"api.score":      `"score" は正しいドットの数です。`,
+		"api.flag":       `"flag" はアタックポイントのフラグです。`,
+
+		"hint":        "ヒント",
+		"hint.region": "（%d〜%d行目、%d〜%d列目）",
+
+		"me.banned":       "あなたのチームは停止されています。",
+		"me.rank":         "順位",
+		"me.leader":       "（1位を維持しており SLA ポイントを得ています）",
+		"me.behind":       "（1位まで %d）",
+		"me.total":        "合計スコア",
+		"me.next_request": "次にリクエストできる時刻",
+		"me.rate_limited": "レート制限で断られたリクエスト",
+		"me.json":         "同じ内容を GET /api/me で JSON として取得できます。",
+
+		"teamflag.unknown":   "不明なチームです。",
+		"teamflag.banned":    "あなたのチームは停止されています。",
+		"teamflag.not_first": "あなたのチームは1位ではありません。",
+
+		"error.invalid_size":        "画像のサイズが正しくありません",
+		"error.not_open":            "問題が公開されていません",
+		"error.rate_limited":        "リクエストが多すぎます",
+		"error.bad_encoding":        "リクエストボディが gzip された画像ではありません",
+		"error.unknown_question":    "存在しない問題です",
+		"error.too_large":           "リクエストボディが大きすぎます",
+		"error.paused":              "問題は一時停止中です",
+		"error.banned":              "あなたのチームは停止されています",
+		"error.not_started":         "ゲームはまだ始まっていません",
+		"error.too_many_candidates": "候補が多すぎます",
+		"error.unknown_team":        "あなたのアドレスはどのチームにも属していません",
+		"error.unknown_round":       "存在しないラウンドです",
+	},
+}
+
+var (
+	catalog     = messages
+	defaultLang = "en"
+)
+
+// setMessages lays the texts of the config over the built-in ones. def is
+// the language of requests that ask for none we have.
+func setMessages(def string, overrides map[string]map[string]string) {
+	c := make(map[string]map[string]string, len(messages)+len(overrides))
+	for lang, texts := range messages {
+		c[lang] = texts
+	}
+	for lang, texts := range overrides {
+		merged := make(map[string]string, len(c["en"]))
+		for k, v := range c[lang] {
+			merged[k] = v
+		}
+		for k, v := range texts {
+			merged[k] = v
+		}
+		c[lang] = merged
+	}
+	catalog = c
+	defaultLang = "en"
+	if _, ok := c[def]; ok {
+		defaultLang = def
+	}
+}
+
+// translate returns the text of key in lang, falling back to English and
+// then to the key itself.
+func translate(lang, key string, args ...interface{}) string {
+	text, ok := catalog[lang][key]
+	if !ok {
+		text, ok = catalog["en"][key]
+	}
+	if !ok {
+		text = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// languages lists the codes of the catalog.
+func languages() []string {
+	langs := make([]string, 0, len(catalog))
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// requestLang picks the language of r from the lang query parameter, then
+// from Accept-Language.
+func requestLang(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if _, ok := catalog[lang]; ok {
+			return lang
+		}
+	}
+	type tag struct {
+		lang string
+		q    float64
+	}
+	tags := []tag{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{lang: strings.ToLower(fields[0]), q: 1}
+		for _, f := range fields[1:] {
+			if f = strings.TrimSpace(f); strings.HasPrefix(f, "q=") {
+				t.q, _ = strconv.ParseFloat(f[2:], 64)
+			}
+		}
+		if t.lang != "" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		if _, ok := catalog[t.lang]; ok {
+			return t.lang
+		}
+		if i := strings.Index(t.lang, "-"); i > 0 {
+			if _, ok := catalog[t.lang[:i]]; ok {
+				return t.lang[:i]
+			}
+		}
+	}
+	return defaultLang
+}
+
+// localize returns e with its message in lang. The detail added by
+// withDetail stays as it is.
+func (e *APIError) localize(lang string) *APIError {
+	base := messages["en"]["error."+e.Code]
+	text, ok := catalog[lang]["error."+e.Code]
+	if !ok || base == "" || !strings.HasPrefix(e.Message, base) {
+		return e
+	}
+	n := *e
+	n.Message = text + strings.TrimPrefix(e.Message, base)
+	return &n
+}
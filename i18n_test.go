@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestLang(t *testing.T) {
+	setMessages("", map[string]map[string]string{"fr": {"title": "Trouvez l'image !"}})
+	defer setMessages("", nil)
+
+	for _, c := range []struct {
+		url, accept, want string
+	}{
+		{"/", "", "en"},
+		{"/", "ja-JP,ja;q=0.9,en;q=0.8", "ja"},
+		{"/", "de, en;q=0.5, ja;q=0.7", "ja"},
+		{"/", "fr-CA", "fr"},
+		{"/?lang=en", "ja", "en"},
+		{"/?lang=xx", "ja", "ja"},
+	} {
+		r := httptest.NewRequest("GET", c.url, nil)
+		r.Header.Set("Accept-Language", c.accept)
+		if got := requestLang(r); got != c.want {
+			t.Errorf("%s with %q: got %s, want %s", c.url, c.accept, got, c.want)
+		}
+	}
+	if got := translate("fr", "ranking"); got != "Ranking" {
+		t.Errorf("missing texts must fall back to English, got %q", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	for _, e := range []*APIError{errInvalidSize, errNotOpen, errRateLimited, errBadEncoding, errUnknownQuestion,
+		errTooLarge, errPaused, errBanned, errNotStarted, errTooManyCandidates, errUnknownCaller} {
+		if messages["en"]["error."+e.Code] != e.Message {
+			t.Errorf("%s: English text differs from %q", e.Code, e.Message)
+		}
+		if e.localize("ja").Message == e.Message {
+			t.Errorf("%s: no Japanese text", e.Code)
+		}
+	}
+	e := errBadEncoding.withDetail("gzip error").localize("ja")
+	if e.Message != "リクエストボディが gzip された画像ではありません (gzip error)" {
+		t.Errorf("detail is lost: %q", e.Message)
+	}
+}
+
+func TestIndexLang(t *testing.T) {
+	tmpl, err := loadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	buf := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(buf, "index.html", indexData{
+		Lang:      "ja",
+		Now:       now,
+		Start:     now.Add(-time.Hour),
+		End:       now.Add(time.Hour),
+		Questions: []QuestionInfo{{Number: 1, Open: now, Status: "open"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "ランキング") || !strings.Contains(buf.String(), "画像1") {
+		t.Error("index.html is not in Japanese")
+	}
+}
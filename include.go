@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix starts the environment variables overriding secrets:
+// FINDIMAGE_FLAGSECRET, FINDIMAGE_ADMINTOKEN and FINDIMAGE_FLAG_<number>.
+const envPrefix = "FINDIMAGE_"
+
+// Secrets are the parts of the config kept out of version control.
+type Secrets struct {
+	FlagSecret string
+	AdminToken string
+	// Flags maps question numbers to their flag.
+	Flags map[int]string
+}
+
+// configSource tells which file each part of a config was read from, to
+// report problems where they are.
+type configSource struct {
+	file string
+	src  []byte
+	// questions holds the file of each question, nil for those of the
+	// config itself, and maps the file of each mapfile.
+	questions []*sourceFile
+	maps      []string
+	// teams holds the teams from teamsFrom on.
+	teams     *sourceFile
+	teamsFrom int
+	// flags holds where the flag of a question was overridden.
+	flags   map[int]string
+	secrets *sourceFile
+}
+
+type sourceFile struct {
+	file string
+	src  []byte
+}
+
+// locate returns the file and the line of path.
+func (s *configSource) locate(path ...interface{}) (string, int) {
+	if len(path) >= 2 {
+		i, _ := path[1].(int)
+		switch {
+		case path[0] == "questions" && len(path) == 3 && path[2] == "flag" && s.flags[i] != "":
+			if s.flags[i] == "secrets" {
+				return s.secrets.file, yamlLine(s.secrets.src, "flags", strconv.Itoa(i+1))
+			}
+			return "$" + s.flags[i], 0
+		case path[0] == "questions" && i < len(s.questions) && s.questions[i] != nil:
+			return s.questions[i].file, yamlLine(s.questions[i].src, path[2:]...)
+		case path[0] == "teams" && s.teams != nil && i >= s.teamsFrom:
+			return s.teams.file, yamlLine(s.teams.src, append([]interface{}{i - s.teamsFrom}, path[2:]...)...)
+		}
+	}
+	return s.file, yamlLine(s.src, path...)
+}
+
+// mapFile returns the mapfile of question i, empty when its map is in the
+// YAML.
+func (s *configSource) mapFile(i int) string {
+	if i < len(s.maps) {
+		return s.maps[i]
+	}
+	return ""
+}
+
+// include reads the include directory of c, the mapfiles and the
+// environment into c. The theme directory is made relative to the config
+// like the others.
+func include(c *Config, s *configSource) error {
+	base := filepath.Dir(s.file)
+	if c.Theme.Dir != "" && !filepath.IsAbs(c.Theme.Dir) {
+		c.Theme.Dir = filepath.Join(base, c.Theme.Dir)
+	}
+	s.questions = make([]*sourceFile, len(c.Questions))
+	s.teamsFrom = len(c.Teams)
+
+	if c.Include != "" {
+		dir := c.Include
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(base, dir)
+		}
+		names, err := filepath.Glob(filepath.Join(dir, "questions", "*.yaml"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			var qc QuestionConfig
+			f, err := readYAML(name, &qc)
+			if err != nil {
+				return err
+			}
+			c.Questions = append(c.Questions, qc)
+			s.questions = append(s.questions, f)
+		}
+
+		var teams []TeamConfig
+		if f, err := readYAML(filepath.Join(dir, "teams.yaml"), &teams); err == nil {
+			c.Teams = append(c.Teams, teams...)
+			s.teams = f
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		var secrets Secrets
+		if f, err := readYAML(filepath.Join(dir, "secrets.yaml"), &secrets); err == nil {
+			applySecrets(c, s, secrets, "secrets")
+			s.secrets = f
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	s.maps = make([]string, len(c.Questions))
+	for i := range c.Questions {
+		qc := &c.Questions[i]
+		if qc.MapFile == "" {
+			continue
+		}
+		name := qc.MapFile
+		if !filepath.IsAbs(name) {
+			dir := base
+			if s.questions[i] != nil {
+				dir = filepath.Dir(s.questions[i].file)
+			}
+			name = filepath.Join(dir, name)
+		}
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rows := strings.Fields(strings.ReplaceAll(string(buf), "\r", ""))
+		qc.Map = strings.Join(rows, " ")
+		s.maps[i] = name
+	}
+
+	env := Secrets{
+		FlagSecret: os.Getenv(envPrefix + "FLAGSECRET"),
+		AdminToken: os.Getenv(envPrefix + "ADMINTOKEN"),
+		Flags:      map[int]string{},
+	}
+	for n := 1; n <= len(c.Questions); n++ {
+		if flag := os.Getenv(envPrefix + "FLAG_" + strconv.Itoa(n)); flag != "" {
+			env.Flags[n] = flag
+		}
+	}
+	applySecrets(c, s, env, "")
+	return nil
+}
+
+// applySecrets overrides c with the non-empty secrets read from "secrets"
+// or, when from is empty, from the environment.
+func applySecrets(c *Config, s *configSource, secrets Secrets, from string) {
+	if secrets.FlagSecret != "" {
+		c.Game.FlagSecret = secrets.FlagSecret
+	}
+	if secrets.AdminToken != "" {
+		c.Admin.Token = secrets.AdminToken
+	}
+	if s.flags == nil {
+		s.flags = map[int]string{}
+	}
+	for n, flag := range secrets.Flags {
+		if n < 1 || n > len(c.Questions) || flag == "" {
+			continue
+		}
+		c.Questions[n-1].Flag = flag
+		s.flags[n-1] = from
+		if from == "" {
+			s.flags[n-1] = envPrefix + "FLAG_" + strconv.Itoa(n)
+		}
+	}
+}
+
+func readYAML(name string, v interface{}) (*sourceFile, error) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(buf, v); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &sourceFile{file: name, src: buf}, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadConfigInclude(t *testing.T) {
+	t.Setenv(envPrefix+"ADMINTOKEN", "0123456789abcdef0123")
+	c, err := readConfig(filepath.Join("example", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := readConfig("config_example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Questions) != len(want.Questions) || len(c.Teams) != len(want.Teams) {
+		t.Fatalf("got %d questions and %d teams", len(c.Questions), len(c.Teams))
+	}
+	for i, qc := range c.Questions {
+		if qc.Map != want.Questions[i].Map || qc.Flag != want.Questions[i].Flag {
+			t.Errorf("question %d differs from config_example.yaml", i+1)
+		}
+	}
+	if c.Game.FlagSecret != "change me" || c.Admin.Token != "0123456789abcdef0123" {
+		t.Error("secrets.yaml or the environment is not read")
+	}
+
+	os.Setenv(envPrefix+"FLAG_2", "SECCON{env}")
+	defer os.Unsetenv(envPrefix + "FLAG_2")
+	c, err = readConfig(filepath.Join("example", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Questions[1].Flag != "SECCON{env}" {
+		t.Errorf("flag is %q, the environment must win", c.Questions[1].Flag)
+	}
+}
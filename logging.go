@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logger writes the JSON log of requests and game events. Flags never
+// appear in it, they are kept in the audit log only.
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{ReplaceAttr: redact}))
+
+// newLogger creates a logger writing at level ("debug", "info", "warn" or
+// "error") to output ("stderr", "stdout" or a file path).
+func newLogger(level, output string) (*slog.Logger, error) {
+	var w io.Writer
+	switch output {
+	case "", "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	default:
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	var lv slog.Level
+	if level != "" {
+		if err := lv.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       lv,
+		ReplaceAttr: redact,
+	})), nil
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == "flag" {
+		return slog.String("flag", "[redacted]")
+	}
+	return a
+}
+
+type loggerKey struct{}
+
+// requestLog gives every request an ID, passed on in X-Request-ID, and
+// logs the request once it is done.
+func requestLog(c *gin.Context) {
+	id := c.GetHeader("X-Request-ID")
+	if id == "" || len(id) > 64 {
+		id = newRequestID()
+	}
+	c.Header("X-Request-ID", id)
+	l := logger.With("request_id", id)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), loggerKey{}, l))
+
+	start := time.Now()
+	c.Next()
+
+	remote := getIpAddr(c.Request)
+	stateMu.RLock()
+	team := Ip2Team(remote)
+	stateMu.RUnlock()
+	l.Info("request",
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"status", c.Writer.Status(),
+		"duration", time.Since(start),
+		"remote", remote,
+		"team", team,
+	)
+}
+
+// requestLogger returns the logger of r, tagged with its request ID.
+func requestLogger(r *http.Request) *slog.Logger {
+	if l, ok := r.Context().Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return logger
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
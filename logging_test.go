@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRequestLogRedactsFlags(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10", Flag: "SECCON{x}"})
+	var err error
+	logger, err = newLogger("debug", "server.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped("01\n10\n"))
+	var resp AnswerResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Flag == "" {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	id := w.Header().Get("X-Request-ID")
+	if id == "" {
+		t.Error("the request must be given an ID")
+	}
+
+	b, err := os.ReadFile("server.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	if !strings.Contains(out, `"flag":"[redacted]"`) {
+		t.Errorf("the issued flag must be logged redacted:\n%s", out)
+	}
+	if strings.Contains(out, resp.Flag) || strings.Contains(out, "SECCON{") {
+		t.Errorf("a flag leaked into the log:\n%s", out)
+	}
+	if !strings.Contains(out, `"msg":"request"`) || !strings.Contains(out, `"request_id":"`+id+`"`) || !strings.Contains(out, `"team":"a"`) {
+		t.Errorf("the request must be logged with its ID and team:\n%s", out)
+	}
+
+	list := audit.List()
+	if len(list) != 1 || list[0].Action != "flag issued" || list[0].Detail != resp.Flag {
+		t.Errorf("the audit log must keep the real flag: %+v", list)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	if _, err := newLogger("loud", "stderr"); err == nil {
+		t.Error("an unknown level must be rejected")
+	}
+	for _, level := range []string{"", "debug", "info", "warn", "error"} {
+		if _, err := newLogger(level, "stdout"); err != nil {
+			t.Errorf("%q: %v", level, err)
+		}
+	}
+}
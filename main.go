@@ -2,8 +2,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"html/template"
 	"io/ioutil"
+	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -16,289 +19,399 @@ var (
 	game     *Game
 	config   *Config
 	ranking  *RankingBoard
+	issuer   *FlagIssuer
+	bans     *BanList
+	audit    *AuditLog
+	stats    *Stats
+	// candidates holds the best candidate of each team per question.
+	candidates *CandidateArchive
+	// clock is the time of the game, shifted and sped up for dry runs.
+	clock Clock = realClock{}
 
-	pathConfig = flag.String("config", "config_example.yaml", "path to config.yaml")
-	addr       = flag.String("addr", ":8080", "receive address")
+	pathConfig  = flag.String("config", "config_example.yaml", "path to config.yaml")
+	addr        = flag.String("addr", ":8080", "receive address")
+	metricsAddr = flag.String("metrics-addr", "", "receive address of /metrics, the main address when empty")
+	timeScale   = flag.Float64("time-scale", 1, "how many times faster than real time the game runs, for dry runs")
+	timeOffset  = flag.Duration("time-offset", 0, "how far ahead of real time the game runs, for dry runs")
 )
 
 type Config struct {
+	// Include is a directory, relative to the config, with a file per
+	// question in questions/*.yaml, teams.yaml and secrets.yaml.
+	Include   string
 	Questions []QuestionConfig
+	Rounds    []RoundConfig
+	Teams     []TeamConfig
 	Game      struct {
 		Start    time.Time
 		End      time.Time
 		Interval float64
+		// FlagSecret watermarks attack flags per team when set.
+		FlagSecret string
+	}
+	Server struct {
+		// Timeouts in seconds, 0 means the default.
+		ReadTimeout  float64
+		WriteTimeout float64
+	}
+	Log struct {
+		// Level is debug, info, warn or error and Output stderr, stdout
+		// or the path of a file.
+		Level  string
+		Output string
+	}
+	Theme struct {
+		// Dir overrides the embedded templates/*.html and css/* with the
+		// files of the same path under it.
+		Dir string
+	}
+	I18n struct {
+		// Default is the language of requests asking for none we have, en
+		// when empty. Messages overrides or adds texts per language.
+		Default  string
+		Messages map[string]map[string]string
+	}
+	Admin struct {
+		// Token is required as "Authorization: Bearer <token>" on /admin.
+		Token string
 	}
 }
 
+// RoundConfig is a stage of the game. Start and End are seconds from the
+// start of the game, a zero End lasts until the next round or the end of
+// the game.
+type RoundConfig struct {
+	Name  string
+	Start int
+	End   int
+	// Questions are the numbers of the questions of the round, they are
+	// open only while the round is.
+	Questions []int
+	// Interval replaces game.interval during the round and Multiplier
+	// weighs its scores, 1 by default.
+	Interval   float64
+	Multiplier float64
+}
+
+// TeamConfig maps the addresses starting with Address to the team Name.
+type TeamConfig struct {
+	Name    string
+	Address string
+}
+
 type QuestionConfig struct {
-	Map  string
-	Flag string
-	Open int
+	// Map is the image as rows of 0 and 1 separated by spaces, or MapFile
+	// a text file with a row per line, relative to the YAML file.
+	Map     string
+	MapFile string
+	Flag    string
+	// Open and Close are seconds from the start of the game, a zero Close
+	// means the question closes with the game.
+	Open  int
+	Close int
+	// Threshold is the ratio of wrong dots below which the flag is given,
+	// 0.1 by default.
+	Threshold float64
+	// Batch is the number of candidates accepted by /answer/:number/batch
+	// and BatchCost the rate limiter charge of each one. Both default to 1.
+	Batch     int
+	BatchCost float64
+	Hints     []HintConfig
+}
+
+type HintConfig struct {
+	Text string
+	// After is seconds from the start of the game until the release.
+	After  int
+	Region *HintRegion
 }
 
 func main() {
 	flag.Parse()
-	loadConfig(*pathConfig)
+	if flag.Arg(0) == "validate" {
+		os.Exit(cmdValidate(*pathConfig))
+	}
+	if flag.Arg(0) == "client" {
+		os.Exit(cmdClient(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "author" {
+		os.Exit(cmdAuthor(flag.Args()[1:]))
+	}
+	if err := loadConfig(*pathConfig); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	setTeams(config.Teams)
+	setMessages(config.I18n.Default, config.I18n.Messages)
+
+	switch flag.Arg(0) {
+	case "verifyflag":
+		os.Exit(cmdVerifyFlag(flag.Args()[1:]))
+	case "simulate":
+		os.Exit(cmdSimulate(flag.Args()[1:]))
+	}
 
+	if *timeScale <= 0 {
+		fmt.Fprintln(os.Stderr, "-time-scale must be positive")
+		os.Exit(2)
+	}
+	clock = newClock(*timeScale, *timeOffset)
 	var err error
-	game, err = NewGame(config.Game.Start, config.Questions)
+	logger, err = newLogger(config.Log.Level, config.Log.Output)
+	if err != nil {
+		panic(err)
+	}
+	for _, w := range configWarnings(config) {
+		logger.Warn(w)
+	}
+	if err := setup(); err != nil {
+		panic(err)
+	}
+	go func() {
+		// a tick per second of game time, as often as the system allows
+		for range time.Tick(max(time.Duration(float64(time.Second) / *timeScale), time.Millisecond)) {
+			tick(clock.Now())
+		}
+	}()
+	go watchReload(*pathConfig)
+	tmpl, err := loadTemplates(config.Theme.Dir)
 	if err != nil {
 		panic(err)
 	}
-	ranking = NewRankingBoard(config.Game.Start, config.Questions)
-	iBreaker = NewIntervalBreaker(time.Duration(config.Game.Interval * float64(time.Second)))
-	tmpl := template.New("html")
-	tmpl = template.Must(tmpl.New("index.html").Parse(tmplIndexHtml))
 
-	r := gin.Default()
+	if config.Log.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := newRouter(tmpl)
+
+	if *metricsAddr != "" {
+		m := gin.New()
+		m.GET("/metrics", viewMetrics())
+		go func() {
+			if err := m.Run(*metricsAddr); err != nil {
+				panic(err)
+			}
+		}()
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           r,
+		ReadHeaderTimeout: seconds(config.Server.ReadTimeout, 10*time.Second),
+		ReadTimeout:       seconds(config.Server.ReadTimeout, 10*time.Second),
+		WriteTimeout:      seconds(config.Server.WriteTimeout, 10*time.Second),
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		panic(err)
+	}
+}
+
+// setup creates the state of the game from config.
+func setup() error {
+	var err error
+	game, err = NewGame(clock, config.Game.Start, config.Game.End, config.Questions, config.Rounds)
+	if err != nil {
+		return err
+	}
+	ranking, err = NewRankingBoardFromFile(clock, "ranking_backup.json", config.Game.Start, config.Questions, config.Rounds)
+	if err != nil {
+		return err
+	}
+	candidates = NewCandidateArchive("archive")
+	iBreaker = NewIntervalBreaker(clock, game.Interval(clock.Now(), config.Game.Interval))
+	issuer, err = NewFlagIssuerFromFile(config.Game.FlagSecret, "flags_backup.json")
+	if err != nil {
+		return err
+	}
+	bans, err = NewBanListFromFile("bans_backup.json")
+	if err != nil {
+		return err
+	}
+	audit = NewAuditLog("audit.log")
+	stats = NewStats()
+	return nil
+}
+
+// tick releases the hints and follows the rounds, every second of game
+// time.
+func tick(now time.Time) {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	game.ReleaseHints(now)
+	advanceRound(now, "")
+}
+
+// newRouter routes the pages, the API and the admin of the server.
+func newRouter(tmpl *template.Template) *gin.Engine {
+	r := gin.New()
+	// team names in /admin/teams/:team may hold an escaped slash
+	r.UseRawPath = true
+	r.Use(gin.Recovery(), requestLog, countRequests)
 	r.SetHTMLTemplate(tmpl)
-	r.GET("/", viewIndex).
+	r.StaticFS("/css", http.FS(themeCSS(config.Theme.Dir)))
+	pub := r.Group("/", holdState)
+	pub.GET("/", viewIndex).
 		GET("/teamflag.txt", viewTeamflag).
 		POST("/answer/:number", viewAnswer).
-		Static("/css", "css")
-	r.Run(*addr)
+		POST("/answer/:number/batch", viewBatch).
+		GET("/api/questions", apiQuestions).
+		GET("/api/time", apiTime).
+		GET("/api/me", apiMe).
+		GET("/api/rounds", apiRounds).
+		GET("/me", viewMe)
+	v1 := pub.Group("/api/v1")
+	v1.GET("/questions", apiQuestions).
+		GET("/time", apiTime).
+		GET("/me", apiMe).
+		GET("/rounds", apiRounds)
+	v1.POST("/answer/:number", apiAnswer)
+	v1.POST("/answer/:number/batch", apiBatch)
+	r.GET("/admin/dashboard", viewDashboard)
+	admin := r.Group("/admin", adminAuth)
+	admin.POST("/reload", adminReload)
+	admin.GET("/state", holdState, adminState).
+		POST("/questions/:number/:action", holdState, adminQuestion).
+		POST("/game/pause", holdState, adminPause).
+		POST("/game/resume", holdState, adminResume).
+		POST("/rounds/:name/start", holdState, adminRoundStart).
+		POST("/rounds/schedule", holdState, adminRoundSchedule).
+		POST("/teams/:team/score", holdState, adminScore).
+		POST("/teams/:team/reset", holdState, adminReset).
+		POST("/teams/:team/ban", holdState, adminBan).
+		POST("/teams/:team/unban", holdState, adminUnban).
+		POST("/ranking/save", holdState, adminSave).
+		GET("/archive/:team/:number", holdState, adminArchive)
+	// the counts per team help opponents, they are for the admin only
+	if *metricsAddr == "" {
+		r.GET("/metrics", adminAuth, viewMetrics())
+	}
+	return r
 }
 
-func loadConfig(path string) error {
-	b, err := ioutil.ReadFile(path)
+// seconds converts a duration given in seconds in the config, falling back
+// to def when it is not set.
+func seconds(s float64, def time.Duration) time.Duration {
+	if s <= 0 {
+		return def
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// cmdVerifyFlag prints the team each given flag was issued to.
+func cmdVerifyFlag(flags []string) int {
+	if len(flags) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: verifyflag FLAG...")
+		return 2
+	}
+	fi, err := NewFlagIssuerFromFile(config.Game.FlagSecret, "flags_backup.json")
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-	config = &Config{}
-	err = yaml.Unmarshal(b, config)
+	status := 0
+	for _, f := range flags {
+		team, ok := fi.Owner(f, teamNames())
+		if !ok {
+			fmt.Printf("%s\tunknown\n", f)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s\t%s\n", f, team)
+	}
+	return status
+}
+
+func loadConfig(path string) error {
+	c, err := readConfig(path)
 	if err != nil {
 		return err
 	}
+	config = c
 	return nil
 }
 
-var tmplIndexHtml = `
-<!DOCTYPE html>
-<html>
-<head>
-	<meta http-equiv="X-UA-Compatible" content="IE=edge">
-	<meta charset="utf-8">
-	<title></title>
-	<meta name="description" content="">
-	<meta name="author" content="">
-	<meta name="viewport" content="width=device-width, initial-scale=1">
-	<link rel="stylesheet" href="css/github-markdown.css">
-	<!--[if lt IE 9]>
-	<script src="//cdnjs.cloudflare.com/ajax/libs/html5shiv/3.7.2/html5shiv.min.js"></script>
-	<script src="//cdnjs.cloudflare.com/ajax/libs/respond.js/1.4.2/respond.min.js"></script>
-	<![endif]-->
-	<link rel="shortcut icon" href="">
-</head>
-<body><div class="markdown-body" style="width:650px; margin:0 auto; padding:45px;">
-	<h1>Find the Image!</h1>
-	<h2>Ranking</h2>
-	<table style="width:100%;">
-		<thead>
-			<tr><td rowspan=2>Rank</td><td rowspan=2>Name</td><td colspan=4>SCORE</td></tr>
-			<tr><td>image1</td><td>image2</td><td>image3</td><td>total</td></tr>
-		</thead>
-		<tbody>
-			{{range .}}
-			<tr><td>1</td><td>{{.Name}}</td><td>{{index .Score 0}}</td><td>{{index .Score 1}}</td><td>{{index .Score 2}}</td><td>{{.TotalScore}}</td></tr>
-			{{end}}
-		</tbody>
-	</table>
-	<h2>About this game</h2>
-	<ul>
-		<li>There are 3 hidden imgaes.</li>
-		<li>Please find all complete images.</li>
-		<li>Image size is 130 * 130, And dot is black or white(binary image).</li>
-		<li>Server will return count of different dots from your sending candidate image.</li>
-		<li>You can try only 1 request/sec.</li>
-		<li>You will get SLA points while you are staying 1st.</li>
-		<li>Server give you a flag if more than 95% is correct.</li>
-	</ul>
-	<h3>API: POST /answer/:(image number - 1 or 2 or 3)</h3>
-	<h4>Request Body</h4>
-	<ul>
-		<li>Request body is your candidate image.</li>
-		<li>Please compress by gzip.</li>
-	</ul>
-	example:
-	<pre>0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000011111000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000011111111111000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000111111111111100000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000011111111111110000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000011111111111111000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000001111111111111000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000001111111111111100000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000001111111111111000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000011111111111111000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000111111111111110000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000001111111111111100000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000011111111111110000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000111111111111100000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000001111111111110000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000011111111111000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000111111111100000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000011111100000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000111111111110000000000000000000000000000000000000000001111111111111100000000000000000000000000000000000
-0000000000000000000000000111111111111111110000000000000000000000000000000000001111111111111111111000000000000000000000000000000000
-0000000000000000000000000111111111111111111000000000000000000000000000000001111111111111111111111110000000000000000000000000000000
-0000000000000000000000000011111111111111111110000000000000000000000000000111111111111111111111111111000000000000000000000000000000
-0000000000000000000000000001111111111111111111000000000100000000000000001111111111111111111111111111100000000000000000000000000000
-0000000000000000000000000000011111111111111111000000001110000000000111111111000000000000011111111111110000000000000000000000000000
-0000000000000000000000000000001111111111111111000000001110000000000011111111100000000000001111111111110000000000000000000000000000
-0000000000000000000000000000000011111111111110000000001111000000000001111111110000000000000111111111110000000000000000000000000000
-0000000000000000000000000000000000111111111100000000001111100000000000111111111000000000000011111111111000000000000000000000000000
-0000000000000000000000000000000000000111100000000000001111100000000000011111111100000000000011111111111000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111110000000000011111111100000000000011111111111000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111111000000000011111111110000000000011111111111000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111111000000000011111111110000000000011111111110000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111111100000000001111111111110000000011111111110000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111111100000000011111111111111100000011111111110000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111111110000011111111111111111110000011111111110000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111111110001111111111111111111110000011111111110000000000000000000000000000
-0000000000000000000000000000000000000000000000000000011111111111111111111111111111111110000011111111110000000000000000000000000000
-0000000000000000000000000000000000000000000000000000001111111111111111111111111111111110000011111111110000000000000000000000000000
-0000000000000000000000000000000000000000000000000000001111111111111111111111111111111100000111111111100000000000000000000000000000
-0000000000000000000000000000000000011111111100000000001111111111001111111111111111110000000111111111100000000000000000000000000000
-0000000000000000000000000000000001111111111110000000001111111111000001111111111110000000000111111111100000000000000000000000000000
-0000000000000000000000000000001111111111111111100000001111111111000000011111111110000000000111111111100000000000000000000000000000
-0000000000000000000000000001111111111111111111100000001111111111000000011111111110000000001111111111000000000000000000000000000000
-0000000000000000000000001111111111111111111111100000001111111111000000011111111110000000001111111111000000000000000000000000000000
-0000000000000000000001111111111111111111111111000000000111111111000000011111111100000000001111111111000000000000000000000000000000
-0000000000000000111111111111111111111111111110000000000111111111000000011111111100000000011111111110000000000000000000000000000000
-0000000111111111111111111111111111111111111000000000000111111111000000111111111100000000011111111110000000000000000000000000000000
-0000000011111111111111111111111111111111110000000000000011111111000000111111111000000000111111111100000000000000000000000000000000
-0000000011111111111111111111111111111111100000000000000011111111000000111111111000111111111111111100000000000000000000000000000000
-0000000001111111111111111111111111111110000000000000000001111111000001111111111111111111111111111100000000000000000000000000000000
-0000000001111111111111111111111111111100000000000000000001111111000001111111111111111111111111111000000000000000000000000000000000
-0000000000111111111111111111111111111000000000000000000000111111000111111111111111111111111111111000000000000000000000000000000000
-0000000000011111111111111111111111110000000000000000000000011111111111111111111111111111111111110000000000000000000000000000000000
-0000000000001111111111111111111111100000000000000000000000001111111111111111111111111111111111110000000000000000000000000000000000
-0000000000000011111111111111111111000000000000000000000000001111111111111111111111111011111111100000000000000000000000000000000000
-0000000000000000000000011111111110000000000000010000000000000111111111111111111111100000111111000000000000000000000000000000000000
-0000000000000000000000111111111100000011000000011000000000000111111111111111111100000000011100000000000000000000000000000000000000
-0000000000000000000001111111111000001111111110011000000000000011111111111111100000000000000011100000000000000000000000000000000000
-0000000000000000000011111111110000001111111111111000000000000000111111111101111000000000011111111111000000000000000000000000000000
-0000000000000000000111111111100000000111111111111100000000000000000111110011111111000000001111111111110000000000000000000000000000
-0000000000000000001111111111000000001111111111111100000000000000001111110011111111000000001111111111110000000000000000000000000000
-0000000000000000011111111110000000111111111111111100000000000000011111100011111111000000001111111111100000000000000000000000000000
-0000000000000000111111111110000111111111111111111110000000000000111111000011111111000000001111111111100000000000000000000000000000
-0000000000000001111111111100111111111111111111111110000000000001111111000011111111000000011111111111100000000000000000000000000000
-0000000000000011111111111011111111111111111111111111000000000011111110000111111111000000011111111111000000000000000000000000000000
-0000000000000111111111111111111111111111111111111111000000000011111110001111111110000000111111111111000000000000000000000000000000
-0000000000001111111111111111111111111111110011111111100000000111111100001111111110000001111111111110000000000000000000000000000000
-0000000000011111111111111111111111111111100001111111100000001111111000011111111100000011111111111110000000000000000000000000000000
-0000000000011111111111111111111111111110000000111111100000011111111000011111111000000111111111111100000000000000000000000000000000
-0000000000011111111111111111111111111100000000011111100000111111110000011111111000001111111111111000000000000000000000000000000000
-0000000000011111111111111111111111110000000000001000000001111111100000111111110000011111111111111000000001100000000000000000000000
-0000000000001111111111111111111110000000000000000000000011111111100000111111110000111111111111110000011111111000000000000000000000
-0000000000001111111111111111110000000000000000000000000011111111000001111111110001111111111111100011111111111100000000000000000000
-0000000000000111111111111110000000000000000000000000000111111110000001111111100001111111111111111111111111111110000000000000000000
-0000000000000111111111110000000000000000000000000000001111111110000001111111100011111111111111111111111111111111000000000000000000
-0000000000000011111100000000000000000000000000000000011111111100000001111111100011111111111111111111111111111111100000000000000000
-0000000000000000100000000000000000000000000000000000111111111000000011111111100001111111111111111111111111111111100000000000000000
-0000000000000000000000000000000000000000000000000001111111111000000011111111100001111111111111111110000111111111110000000000000000
-0000000000000000000000000000000000000000000000000011111111110000000011111111000000111111111111000000001111111111100000000000000000
-0000000000000000000000000000000000000000000000000111111111100000000011111111000000111111000000000000011111111111000000000000000000
-0000000000000000000000000000000000000000000000001111111111000000000011111111000000000000000000000001111111111110000000000000000000
-0000000000000000000000000000000000000000000000011111111110000000000011111111000000000000000000000011111111111000000000000000000000
-0000000000000000000000000000000000000000000000111111111100000000000011111111000000000000000000000111111111000000000000000000000000
-0000000000000000000000000000000000000000000001111111111000000000000011111111000000000000000000011111110000000000011000000000000000
-0000000000000000000000000000000000000000110011111111111000000000000011111111000000000000000000111110000000000000011100000000000000
-0000000000000000000000000000000000000001111111111111110000000000000001111111100000000000000000000000000000000000011110000000000000
-0000000000000000000000000000000000000011111111111111100000000000000001111111100000000000000000000000000000000000011111000000000000
-0000000000000000000000000000000000000011111111111111000000000000000001111111100000000000000000000000000000000000011111100000000000
-0000000000000000000000000000000000000111111111111110000000000000000000111111110000000000000000000000000000000000011111100000000000
-0000000000000000000000000000000000000111111111111100000000000000000000111111110000000000000000000000000000000000011111110000000000
-0000000000000000000000000000000000000111111111111000000000000000000000011111111000000000000000000000000000000000111111111000000000
-0000000000000000000000000000000000000111111111110000000000000000000000001111111110000000000000000000000000000000111111111100000000
-0000000000000000000000000000000000000111111111100000000000000000000000001111111111000000000000000000000000000000111111111100000000
-0000000000000000000000000000000000000011111111000000000000000000000000000111111111110000000000000000000000000001111111111110000000
-0000000000000000000000000000000000000011111110000000000000000000000000000011111111111100000000000000000000000111111111111110000000
-0000000000000000000000000000000000000011111100000000000000000000000000000001111111111111110000000000000001111111111111111111000000
-0000000000000000000000000000000000000001110000000000000000000000000000000000111111111111111111111111111111111111111111111111000000
-0000000000000000000000000000000000000001000000000000000000000000000000000000011111111111111111111111111111111111111111111111000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000001111111111111111111111111111111111111111111111000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000011111111111111111111111111111111111111111111000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000001111111111111111111111111111111111111111111000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000011111111111111111111111111111111111111110000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000001111111111111111111111111111111111111100000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000011111111111111111111111111111111100000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000011111111111111111111111111100000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000011111111111111111110000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000011111000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000
-0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000</pre>
-	<h4>Response</h4>
-	<ul>
-		<li>"wrong" is count of wrong dots.</li>
-		<li>"score" is count of correct dots.</li>
-		<li>"flag" is a flag for attack point.</li>
-	</ul>
-	example:
-	<pre>{
-	"wrong": 5,
-	"score": 16895,
-	"flag": "SECCON{this is flag}"
-}</pre>
-	<h2>Hint</h2>
-	<ul>
-		<li>image1 is a charactor.</li>
-		<li>image2 is an animal.</li>
-		<li>image3 is some complex charactors.</li>
-	</ul>
-</div></body>
-</html>
-`
+func readConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	err = yaml.Unmarshal(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s := &configSource{file: path, src: b}
+	if err := include(c, s); err != nil {
+		return nil, err
+	}
+	if problems := validateConfig(c, s); len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return c, nil
+}
+
+const unknownTeam = "unknown team"
+
+var teams = map[string]string{
+/*
+	// Players IP addresses
+	"192.168.1.":  "scryptos",
+	"192.168.2.":  "urandom",
+	"192.168.3.":  "nw",
+	"192.168.4.":  "katagaitai",
+	"192.168.5.":  "Jinkai",
+	"192.168.6.":  "Nem",
+	"192.168.7.":  "Pwnladin",
+	"192.168.8.":  "Cykorkinesis",
+	"192.168.9.":  "217",
+	"192.168.10.": "GoatskiN",
+	"192.168.11.": "m1z0r3",
+	"192.168.12.": "0x0",
+	"192.168.13.": "PwnThyBytes",
+	"192.168.14.": "Shellphish",
+	"192.168.15.": "CodeRed",
+	"192.168.16.": "KaSecon",
+	"192.168.17.": "Bushwhackers",
+	"192.168.18.": "TomoriNao",
+*/
+}
 
 func Ip2Team(ipaddr string) string {
-	for key, name := range map[string]string{
-	/*
-		// Players IP addresses
-		"192.168.1.":  "scryptos",
-		"192.168.2.":  "urandom",
-		"192.168.3.":  "nw",
-		"192.168.4.":  "katagaitai",
-		"192.168.5.":  "Jinkai",
-		"192.168.6.":  "Nem",
-		"192.168.7.":  "Pwnladin",
-		"192.168.8.":  "Cykorkinesis",
-		"192.168.9.":  "217",
-		"192.168.10.": "GoatskiN",
-		"192.168.11.": "m1z0r3",
-		"192.168.12.": "0x0",
-		"192.168.13.": "PwnThyBytes",
-		"192.168.14.": "Shellphish",
-		"192.168.15.": "CodeRed",
-		"192.168.16.": "KaSecon",
-		"192.168.17.": "Bushwhackers",
-		"192.168.18.": "TomoriNao",
-	*/
-	} {
+	for key, name := range teams {
 		if strings.HasPrefix(ipaddr, key) {
 			return name
 		}
 	}
-	return "unknown team"
+	return unknownTeam
+}
+
+// setTeams replaces the team registry with tcs. The built-in registry is
+// kept when the config has no teams.
+func setTeams(tcs []TeamConfig) {
+	if len(tcs) == 0 {
+		return
+	}
+	m := make(map[string]string, len(tcs))
+	for _, tc := range tcs {
+		m[tc.Address] = tc.Name
+	}
+	teams = m
+}
+
+// isTeam tells whether name is a team of the config.
+func isTeam(name string) bool {
+	for _, n := range teams {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func teamNames() []string {
+	names := []string{}
+	for _, name := range teams {
+		names = append(names, name)
+	}
+	return names
 }
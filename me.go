@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errUnknownCaller = &APIError{http.StatusForbidden, "unknown_team", "your address belongs to no team"}
+
+// MeResponse is what a team may know about itself.
+type MeResponse struct {
+	Team   string `json:"team"`
+	Banned bool   `json:"banned"`
+	// Rank is 0 until the team scores, Behind is the gap to the leader.
+	Rank   int  `json:"rank"`
+	Leader bool `json:"leader"`
+	// LeaderSince is when the team took the first place, while it holds it.
+	LeaderSince *time.Time   `json:"leader_since,omitempty"`
+	Behind      int          `json:"behind"`
+	Total       int          `json:"total"`
+	NextRequest time.Time    `json:"next_request"`
+	RateLimited int          `json:"rate_limited"`
+	Questions   []MeQuestion `json:"questions"`
+}
+
+type MeQuestion struct {
+	Number   int    `json:"number"`
+	Status   string `json:"status"`
+	Score    int    `json:"score"`
+	Attempts int    `json:"attempts"`
+	// BestWrong is null when nothing was scored yet.
+	BestWrong *int   `json:"best_wrong"`
+	Flag      string `json:"flag,omitempty"`
+}
+
+type meData struct {
+	MeResponse
+	Lang string
+}
+
+// teamStatus gathers the state of team from the ranking, the limiter and
+// the stats.
+func teamStatus(team string) MeResponse {
+	now := clock.Now()
+	infos := game.Info(now)
+	ts := stats.Get(team, len(infos))
+	me := MeResponse{
+		Team:        team,
+		Banned:      bans.Banned(team),
+		NextRequest: iBreaker.Next(team),
+		RateLimited: ts.RateLimited,
+		Questions:   make([]MeQuestion, len(infos)),
+	}
+	if me.NextRequest.Before(now) {
+		me.NextRequest = now
+	}
+
+	rows := ranking.Scoreboard(infos)
+	scores := make([]int, len(infos))
+	for _, row := range rows {
+		if row.Name != team {
+			continue
+		}
+		me.Rank = row.Rank
+		me.Leader = row.Leader
+		if row.Leader {
+			since := ranking.HeldSince()
+			me.LeaderSince = &since
+		}
+		me.Total = row.Total
+		me.Behind = rows[0].Total - row.Total
+		for i, cell := range row.Cells {
+			scores[i] = cell.Score
+		}
+	}
+
+	flags := map[int]string{}
+	for _, f := range issuer.List() {
+		if f.Team == team {
+			flags[f.Question] = f.Flag
+		}
+	}
+	for i, info := range infos {
+		mq := MeQuestion{
+			Number:   info.Number,
+			Status:   info.Status,
+			Score:    scores[i],
+			Attempts: ts.Attempts[i],
+			Flag:     flags[i],
+		}
+		if ts.BestWrong[i] >= 0 {
+			mq.BestWrong = &ts.BestWrong[i]
+		}
+		me.Questions[i] = mq
+	}
+	return me
+}
+
+func apiMe(c *gin.Context) {
+	team := Ip2Team(getIpAddr(c.Request))
+	if team == unknownTeam {
+		c.JSON(errUnknownCaller.Status, ErrorResponse{Error: errUnknownCaller.localize(requestLang(c.Request))})
+		return
+	}
+	c.JSON(http.StatusOK, teamStatus(team))
+}
+
+func viewMe(c *gin.Context) {
+	team := Ip2Team(getIpAddr(c.Request))
+	if team == unknownTeam {
+		c.String(http.StatusForbidden, translate(requestLang(c.Request), "teamflag.unknown"))
+		return
+	}
+	c.HTML(http.StatusOK, "me.html", meData{
+		MeResponse: teamStatus(team),
+		Lang:       requestLang(c.Request),
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestApiMe(t *testing.T) {
+	r, fake := testServer(t, QuestionConfig{Map: "01 10", Flag: "SECCON{1}"}, QuestionConfig{Map: "01 10", Open: 7200})
+	if w := serve(r, "10.0.0.1", "GET", "/api/me", "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("unknown team: got %d", w.Code)
+	}
+
+	for _, m := range []string{"00\n00\n", "01\n00\n", "01\n10\n"} {
+		if w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped(m)); w.Code != http.StatusOK {
+			t.Fatalf("answer: got %d %s", w.Code, w.Body)
+		}
+		fake.Add(time.Second)
+	}
+	serve(r, "192.168.2.1", "POST", "/api/v1/answer/1", "", gzipped("00\n00\n"))
+
+	w := serve(r, "192.168.1.1", "GET", "/api/me", "", nil)
+	var me MeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &me); err != nil {
+		t.Fatal(err, w.Body)
+	}
+	if me.Team != "a" || me.Rank != 1 || !me.Leader || me.LeaderSince == nil || me.Total != 4 || me.Behind != 0 {
+		t.Errorf("unexpected standing %+v", me)
+	}
+	if len(me.Questions) != 2 {
+		t.Fatalf("unexpected questions %+v", me.Questions)
+	}
+	q := me.Questions[0]
+	if q.Attempts != 3 || q.BestWrong == nil || *q.BestWrong != 0 || q.Flag == "" {
+		t.Errorf("unexpected question 1 %+v", q)
+	}
+	if q := me.Questions[1]; q.Status != "upcoming" || q.BestWrong != nil {
+		t.Errorf("unexpected question 2 %+v", q)
+	}
+
+	var other MeResponse
+	json.Unmarshal(serve(r, "192.168.2.1", "GET", "/api/me", "", nil).Body.Bytes(), &other)
+	if other.Rank != 2 || other.Leader || other.LeaderSince != nil || other.Behind != 2 || other.Questions[0].Flag != "" {
+		t.Errorf("unexpected standing of b %+v", other)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "findimage_http_requests_total",
+		Help: "HTTP requests by endpoint and status.",
+	}, []string{"endpoint", "status"})
+	metricAnswers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "findimage_answers_total",
+		Help: "Scored candidate images by question and team.",
+	}, []string{"question", "team"})
+	metricRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "findimage_rate_limited_total",
+		Help: "Requests turned down by the interval breaker by team.",
+	}, []string{"team"})
+	metricParseFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "findimage_parse_failures_total",
+		Help: "Candidate images that could not be read by error code.",
+	}, []string{"code"})
+	metricFlags = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "findimage_flags_issued_total",
+		Help: "Attack flags handed out by question.",
+	}, []string{"question"})
+	metricTry = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "findimage_try_duration_seconds",
+		Help:    "Time spent comparing a candidate with the hidden image.",
+		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 8),
+	})
+	metricSave = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "findimage_ranking_save_duration_seconds",
+		Help:    "Time spent saving the ranking.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		metricRequests,
+		metricAnswers,
+		metricRateLimited,
+		metricParseFailures,
+		metricFlags,
+		metricTry,
+		metricSave,
+		gameCollector{},
+	)
+}
+
+// gameCollector reports the state of the game at scrape time.
+type gameCollector struct{}
+
+var (
+	descLeader = prometheus.NewDesc("findimage_leader", "Team in first place, 1 for the leader.", []string{"team"}, nil)
+	descTeams  = prometheus.NewDesc("findimage_teams", "Teams registered and on the ranking.", []string{"state"}, nil)
+)
+
+func (gameCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descLeader
+	ch <- descTeams
+}
+
+func (gameCollector) Collect(ch chan<- prometheus.Metric) {
+	if ranking == nil {
+		return
+	}
+	if leader, ok := ranking.Leader(); ok {
+		ch <- prometheus.MustNewConstMetric(descLeader, prometheus.GaugeValue, 1, leader.Name)
+	}
+	stateMu.RLock()
+	registered := len(teams)
+	stateMu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(descTeams, prometheus.GaugeValue, float64(registered), "registered")
+	ch <- prometheus.MustNewConstMetric(descTeams, prometheus.GaugeValue, float64(len(ranking.Snapshot())), "ranked")
+}
+
+// countRequests counts every request by route and status.
+func countRequests(c *gin.Context) {
+	c.Next()
+	endpoint := c.FullPath()
+	if endpoint == "" {
+		endpoint = "unknown"
+	}
+	metricRequests.WithLabelValues(endpoint, strconv.Itoa(c.Writer.Status())).Inc()
+}
+
+func viewMetrics() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+func observe(h prometheus.Histogram, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsAdminOnly(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10"})
+	for token, code := range map[string]int{
+		"":                 http.StatusUnauthorized,
+		"k7Qw9zX2pL4mN8vR": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.RemoteAddr = "192.168.1.1:1024"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("token %q: got %d, want %d", token, w.Code, code)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
+	"os"
 	"sort"
 	"sync"
 	"time"
@@ -12,10 +14,22 @@ import (
 type RankingBoard struct {
 	List      map[string]RankingItem
 	Questions []QuestionConfig
-	Start     time.Time
-	mu        *sync.Mutex
+	Rounds    []RoundConfig
+	// RoundScores holds the score of each team per round, taken when the
+	// round was left.
+	RoundScores map[string]map[string]int
+	Start       time.Time
+	// LeaderSince is when the current leader took the first place.
+	LeaderSince time.Time
+	clock       Clock
+	mu          *sync.Mutex
 }
 
+var (
+	ErrUnknownTeam  = errors.New("unknown team")
+	ErrNoSubmission = errors.New("no submission")
+)
+
 type RankingItemList []RankingItem
 
 type RankingItem struct {
@@ -23,32 +37,51 @@ type RankingItem struct {
 	Name       string
 	Score      []int
 	TotalScore int
+	// Reached is when the team reached TotalScore, the earlier of two
+	// teams with the same total ranks first.
+	Reached time.Time
 }
 
-func NewRankingBoard(start time.Time, qs []QuestionConfig) *RankingBoard {
+func NewRankingBoard(clock Clock, start time.Time, qs []QuestionConfig, rounds []RoundConfig) *RankingBoard {
 	return &RankingBoard{
-		List:      make(map[string]RankingItem),
-		Start:     start,
-		Questions: qs,
-		mu:        &sync.Mutex{},
+		clock:       clock,
+		List:        make(map[string]RankingItem),
+		Start:       start,
+		Questions:   qs,
+		Rounds:      rounds,
+		RoundScores: make(map[string]map[string]int),
+		mu:          &sync.Mutex{},
 	}
 }
 
-func NewRankingBoardFromFile(path string) (*RankingBoard, error) {
-	l := &RankingBoard{}
+// NewRankingBoardFromFile is NewRankingBoard with the ranking saved to
+// path, if there is one.
+func NewRankingBoardFromFile(clock Clock, path string, start time.Time, qs []QuestionConfig, rounds []RoundConfig) (*RankingBoard, error) {
+	l := NewRankingBoard(clock, start, qs, rounds)
 	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	err = json.Unmarshal(buf, l)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
-	l.mu = &sync.Mutex{}
+	if l.List == nil {
+		l.List = make(map[string]RankingItem)
+	}
+	if l.RoundScores == nil {
+		l.RoundScores = make(map[string]map[string]int)
+	}
+	l.Resize(start, qs, rounds)
 	return l, nil
 }
 
-func (rb *RankingBoard) Append(ipaddr string, number, score int) (rankup, befirst bool) {
+// Append records score of the team at ipaddr on question number. improved
+// tells whether it beats the previous score of the team.
+func (rb *RankingBoard) Append(ipaddr string, number, score int) (improved, rankup, befirst bool) {
 	team := Ip2Team(ipaddr)
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
@@ -56,6 +89,7 @@ func (rb *RankingBoard) Append(ipaddr string, number, score int) (rankup, befirs
 	changed := false
 	_, ok := rb.List[team]
 	oldRank := rb.Rank(team)
+	leader := rb.leader()
 	if !ok {
 		changed = true
 		rb.List[team] = rb.createNewItem(ipaddr)
@@ -69,23 +103,96 @@ func (rb *RankingBoard) Append(ipaddr string, number, score int) (rankup, befirs
 	if changed {
 		// TODO: dirty
 		m := rb.List[team]
-		m.TotalScore = rb.List[team].totalScore()
+		rb.setTotal(&m)
 		rb.List[team] = m
+		rb.follow(leader)
 
-		err := rb.Save("ranking_backup.json")
+		err := rb.save("ranking_backup.json")
 		if err != nil {
-			log.Println(err.Error())
+			logger.Error("ranking save", "error", err)
 		}
 
 		newRank := rb.Rank(team)
 		if oldRank < newRank {
 			if newRank == 1 {
-				return true, true
+				return true, true, true
 			}
-			return true, false
+			return true, true, false
+		}
+	}
+	return changed, false, false
+}
+
+// Resize follows a change of the questions, scores of questions that
+// still exist are kept.
+func (rb *RankingBoard) Resize(start time.Time, qs []QuestionConfig, rounds []RoundConfig) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.Start = start
+	rb.Questions = qs
+	rb.Rounds = rounds
+	for team, item := range rb.List {
+		score := make([]int, len(qs))
+		copy(score, item.Score)
+		item.Score = score
+		item.TotalScore = rb.total(item)
+		rb.List[team] = item
+	}
+}
+
+// SetScore overwrites the score of team on question number, even with a
+// lower one.
+func (rb *RankingBoard) SetScore(team string, number, score int) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	item, ok := rb.List[team]
+	if !ok {
+		return ErrUnknownTeam
+	}
+	if number < 0 || number >= len(item.Score) {
+		return ErrUnknownQuestion
+	}
+	leader := rb.leader()
+	item.Score[number] = score
+	rb.setTotal(&item)
+	rb.List[team] = item
+	rb.follow(leader)
+	return rb.save("ranking_backup.json")
+}
+
+// Reset clears every score of team.
+func (rb *RankingBoard) Reset(team string) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	item, ok := rb.List[team]
+	if !ok {
+		return ErrUnknownTeam
+	}
+	leader := rb.leader()
+	item.Score = make([]int, len(rb.Questions))
+	rb.setTotal(&item)
+	rb.List[team] = item
+	rb.follow(leader)
+	return rb.save("ranking_backup.json")
+}
+
+// Backup saves the ranking to path while no score changes.
+func (rb *RankingBoard) Backup(path string) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.save(path)
+}
+
+// Earned tells whether any team has scored on question number.
+func (rb *RankingBoard) Earned(number int) bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	for _, item := range rb.List {
+		if number < len(item.Score) && item.Score[number] > 0 {
+			return true
 		}
 	}
-	return false, false
+	return false
 }
 
 func (rb *RankingBoard) createNewItem(ipaddr string) RankingItem {
@@ -105,6 +212,73 @@ func (rb *RankingBoard) Get() []RankingItem {
 	return list
 }
 
+// Snapshot is Get for callers that do not hold the lock.
+func (rb *RankingBoard) Snapshot() []RankingItem {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.Get()
+}
+
+type ScoreboardRow struct {
+	Rank   int
+	Name   string
+	Leader bool
+	Cells  []ScoreboardCell
+	// Rounds is the score per round, Total the sum weighted by the rounds.
+	Rounds []int
+	Total  int
+	// Percent is the ratio of correct dots over every question opened so far.
+	Percent float64
+}
+
+type ScoreboardCell struct {
+	Upcoming bool
+	Score    int
+	Percent  float64
+}
+
+// Scoreboard lays out the ranking for the questions in infos. Teams with
+// the same total share a rank and the leader is the team holding the SLA.
+func (rb *RankingBoard) Scoreboard(infos []QuestionInfo) []ScoreboardRow {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rows := []ScoreboardRow{}
+	for i, item := range rb.Get() {
+		row := ScoreboardRow{
+			Rank:   i + 1,
+			Name:   item.Name,
+			Leader: i == 0,
+			Total:  item.TotalScore,
+		}
+		if i > 0 && rows[i-1].Total == item.TotalScore {
+			row.Rank = rows[i-1].Rank
+		}
+		dots := 0
+		for n, info := range infos {
+			cell := ScoreboardCell{Upcoming: info.Status == "upcoming"}
+			if n < len(item.Score) {
+				cell.Score = item.Score[n]
+			}
+			if size := info.Width * info.Height; size > 0 {
+				cell.Percent = float64(cell.Score) * 100 / float64(size)
+			}
+			if !cell.Upcoming {
+				dots += info.Width * info.Height
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		if dots > 0 {
+			row.Percent = float64(item.totalScore()) * 100 / float64(dots)
+		}
+		for _, rc := range rb.Rounds {
+			row.Rounds = append(row.Rounds, rb.roundScore(item, rc))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func (rb *RankingBoard) Rank(name string) int {
 	for rank, i := range rb.Get() {
 		if i.Name == name {
@@ -114,21 +288,67 @@ func (rb *RankingBoard) Rank(name string) int {
 	return 0xff
 }
 
-func (rb RankingBoard) Save(path string) error {
+// save writes the ranking to path, the caller holds the lock.
+func (rb *RankingBoard) save(path string) error {
+	defer observe(metricSave, time.Now())
 	buf, err := json.Marshal(rb)
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(path, buf, 0666)
-	if err != nil {
-		return err
+	return writeFileAtomic(path, buf)
+}
+
+func (rb *RankingBoard) SLA() string {
+	leader, ok := rb.Leader()
+	if !ok {
+		return ""
 	}
-	return nil
+	return leader.IpAddress
 }
 
-func (rb RankingBoard) SLA() string {
+// Leader returns the team currently in first place. ok is false while
+// nobody has scored yet.
+func (rb *RankingBoard) Leader() (leader RankingItem, ok bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
 	list := rb.Get()
-	return list[0].IpAddress
+	if len(list) == 0 {
+		return RankingItem{}, false
+	}
+	return list[0], true
+}
+
+// HeldSince returns when the current leader took the first place.
+func (rb *RankingBoard) HeldSince() time.Time {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.LeaderSince
+}
+
+// setTotal updates the total of item and when it was reached.
+func (rb *RankingBoard) setTotal(item *RankingItem) {
+	if total := rb.total(*item); total != item.TotalScore || item.Reached.IsZero() {
+		item.TotalScore = total
+		item.Reached = rb.clock.Now()
+	}
+}
+
+// leader returns the name of the team in first place, empty while the
+// ranking is empty.
+func (rb *RankingBoard) leader() string {
+	list := rb.Get()
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0].Name
+}
+
+// follow starts the lead of a new leader now, before is the leader before
+// the scores changed.
+func (rb *RankingBoard) follow(before string) {
+	if rb.leader() != before {
+		rb.LeaderSince = rb.clock.Now()
+	}
 }
 
 func (ri RankingItem) totalScore() int {
@@ -139,11 +359,118 @@ func (ri RankingItem) totalScore() int {
 	return s
 }
 
+// weight is the multiplier of the round of question number, 1 when it is
+// in no round.
+func (rb *RankingBoard) weight(number int) float64 {
+	for _, rc := range rb.Rounds {
+		for _, n := range rc.Questions {
+			if n == number+1 && rc.Multiplier > 0 {
+				return rc.Multiplier
+			}
+			if n == number+1 {
+				return 1
+			}
+		}
+	}
+	return 1
+}
+
+// total is the score of ri with the multipliers of the rounds.
+func (rb *RankingBoard) total(ri RankingItem) int {
+	s := 0.0
+	for n, v := range ri.Score {
+		s += float64(v) * rb.weight(n)
+	}
+	return int(s + 0.5)
+}
+
+// roundScore is the score of ri on the questions of round rc.
+func (rb *RankingBoard) roundScore(ri RankingItem, rc RoundConfig) int {
+	s := 0.0
+	for _, n := range rc.Questions {
+		if n >= 1 && n <= len(ri.Score) {
+			s += float64(ri.Score[n-1])
+		}
+	}
+	if rc.Multiplier > 0 {
+		s *= rc.Multiplier
+	}
+	return int(s + 0.5)
+}
+
+// SnapshotRound keeps the current score of every team in round name.
+func (rb *RankingBoard) SnapshotRound(name string) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	for _, rc := range rb.Rounds {
+		if rc.Name != name {
+			continue
+		}
+		scores := make(map[string]int, len(rb.List))
+		for team, item := range rb.List {
+			scores[team] = rb.roundScore(item, rc)
+		}
+		if rb.RoundScores == nil {
+			rb.RoundScores = make(map[string]map[string]int)
+		}
+		rb.RoundScores[name] = scores
+		return rb.save("ranking_backup.json")
+	}
+	return ErrUnknownRound
+}
+
+type RoundRank struct {
+	Rank  int    `json:"rank"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+// RoundRanking ranks the teams on round name, from its snapshot when
+// there is one and snapshot is set.
+func (rb *RankingBoard) RoundRanking(name string, snapshot bool) []RoundRank {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	scores, ok := rb.RoundScores[name]
+	if !snapshot || !ok {
+		scores = map[string]int{}
+		for _, rc := range rb.Rounds {
+			if rc.Name == name {
+				for team, item := range rb.List {
+					scores[team] = rb.roundScore(item, rc)
+				}
+			}
+		}
+	}
+	list := make([]RoundRank, 0, len(scores))
+	for team, score := range scores {
+		list = append(list, RoundRank{Name: team, Score: score})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score == list[j].Score {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Score > list[j].Score
+	})
+	for i := range list {
+		list[i].Rank = i + 1
+		if i > 0 && list[i].Score == list[i-1].Score {
+			list[i].Rank = list[i-1].Rank
+		}
+	}
+	return list
+}
+
 func (l RankingItemList) Len() int {
 	return len(l)
 }
 
 func (l RankingItemList) Less(i, j int) bool {
+	if l[i].TotalScore == l[j].TotalScore {
+		if !l[i].Reached.Equal(l[j].Reached) {
+			return l[i].Reached.Before(l[j].Reached)
+		}
+		return l[i].Name < l[j].Name
+	}
 	return l[i].TotalScore > l[j].TotalScore
 }
 
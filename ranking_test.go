@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRankingBoardLeader(t *testing.T) {
+	rb := NewRankingBoard(realClock{}, time.Now(), make([]QuestionConfig, 2), nil)
+	if _, ok := rb.Leader(); ok {
+		t.Error("empty board must not have a leader")
+	}
+	if rb.SLA() != "" {
+		t.Error("empty board must not have an SLA holder")
+	}
+
+	rb.List["a"] = RankingItem{IpAddress: "192.168.1.1", Name: "a", Score: []int{1, 2}, TotalScore: 3}
+	rb.List["b"] = RankingItem{IpAddress: "192.168.2.1", Name: "b", Score: []int{5, 0}, TotalScore: 5}
+	leader, ok := rb.Leader()
+	if !ok || leader.Name != "b" {
+		t.Errorf("leader is %q, want %q", leader.Name, "b")
+	}
+	if rb.SLA() != "192.168.2.1" {
+		t.Error("SLA must return the leader's address")
+	}
+}
+
+func TestRankingBoardLeaderConcurrent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setTeams([]TeamConfig{{Name: "a", Address: "192.168.1."}, {Name: "b", Address: "192.168.2."}})
+	rb := NewRankingBoard(realClock{}, time.Now(), make([]QuestionConfig, 1), nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for score := 1; score <= 100; score++ {
+			rb.Append("192.168.1.1", 0, score*2)
+			rb.Append("192.168.2.1", 0, score*2+1)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		rb.Leader()
+		rb.SLA()
+	}
+	<-done
+	if rb.SLA() != "192.168.2.1" {
+		t.Error("SLA must return the leader's address")
+	}
+}
+
+func TestRankingBoardScoreboard(t *testing.T) {
+	rb := NewRankingBoard(realClock{}, time.Now(), make([]QuestionConfig, 2), nil)
+	rb.List["a"] = RankingItem{Name: "a", Score: []int{10, 0}, TotalScore: 10}
+	rb.List["b"] = RankingItem{Name: "b", Score: []int{20, 0}, TotalScore: 20}
+	rb.List["c"] = RankingItem{Name: "c", Score: []int{0, 10}, TotalScore: 10}
+	infos := []QuestionInfo{
+		{Number: 1, Width: 10, Height: 10, Status: "open"},
+		{Number: 2, Width: 10, Height: 10, Status: "upcoming"},
+	}
+
+	rows := rb.Scoreboard(infos)
+	if len(rows) != 3 {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+	for i, want := range []struct {
+		name string
+		rank int
+	}{{"b", 1}, {"a", 2}, {"c", 2}} {
+		if rows[i].Name != want.name || rows[i].Rank != want.rank {
+			t.Errorf("row %d is %s ranked %d, want %s ranked %d", i, rows[i].Name, rows[i].Rank, want.name, want.rank)
+		}
+	}
+	if !rows[0].Leader || rows[1].Leader {
+		t.Error("only the first team must be the leader")
+	}
+	if rows[0].Cells[0].Percent != 20 || rows[0].Percent != 20 {
+		t.Errorf("unexpected percentages: %v", rows[0])
+	}
+	if !rows[2].Cells[1].Upcoming {
+		t.Error("question 2 must be upcoming")
+	}
+}
+
+func TestRankingBoardLeaderSince(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setTeams([]TeamConfig{{Name: "a", Address: "192.168.1."}, {Name: "b", Address: "192.168.2."}})
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	rb := NewRankingBoard(clock, start, make([]QuestionConfig, 1), nil)
+
+	clock.Add(time.Minute)
+	rb.Append("192.168.1.1", 0, 10)
+	clock.Add(time.Minute)
+	rb.Append("192.168.2.1", 0, 5)
+	if since := rb.HeldSince(); !since.Equal(start.Add(time.Minute)) {
+		t.Errorf("a leads since %v", since)
+	}
+	clock.Add(time.Minute)
+	rb.Append("192.168.2.1", 0, 20)
+	if since := rb.HeldSince(); !since.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("b leads since %v", since)
+	}
+}
+
+func TestRankingBoardTie(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setTeams([]TeamConfig{{Name: "a", Address: "192.168.1."}, {Name: "b", Address: "192.168.2."}})
+	clock := NewFakeClock(time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	rb := NewRankingBoard(clock, clock.Now(), make([]QuestionConfig, 2), nil)
+
+	rb.Append("192.168.2.1", 0, 10)
+	clock.Add(time.Minute)
+	rb.Append("192.168.1.1", 0, 10)
+	if leader, _ := rb.Leader(); leader.Name != "b" {
+		t.Errorf("leader is %q, the first to reach the score must lead", leader.Name)
+	}
+	clock.Add(time.Minute)
+	rb.Append("192.168.1.1", 1, 5)
+	clock.Add(time.Minute)
+	rb.Append("192.168.2.1", 1, 5)
+	if leader, _ := rb.Leader(); leader.Name != "a" {
+		t.Errorf("leader is %q, the first to reach the new score must lead", leader.Name)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stateMu guards what reloadConfig swaps: config, game, the team registry
+// and the limiter settings. Player requests hold it for reading so that
+// they never see half of a reload.
+var stateMu sync.RWMutex
+
+func holdState(c *gin.Context) {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	c.Next()
+}
+
+// reloadConfig re-reads the config at path and swaps it in. The ranking
+// and the rate limiter state are kept.
+func reloadConfig(path string) error {
+	c, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	g, err := NewGame(clock, c.Game.Start, c.Game.End, c.Questions, c.Rounds)
+	if err != nil {
+		return err
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if err := checkReload(c, g); err != nil {
+		return err
+	}
+	g.inherit(game)
+	config = c
+	game = g
+	setTeams(c.Teams)
+	setMessages(c.I18n.Default, c.I18n.Messages)
+	iBreaker.SetDuration(g.Interval(clock.Now(), c.Game.Interval))
+	ranking.Resize(c.Game.Start, c.Questions, c.Rounds)
+	logger.Info("config reloaded", "path", path)
+	for _, w := range configWarnings(c) {
+		logger.Warn(w, "path", path)
+	}
+	return nil
+}
+
+// checkReload rejects a config that would break scores already earned.
+func checkReload(c *Config, g *Game) error {
+	if c.Game.FlagSecret != config.Game.FlagSecret {
+		return fmt.Errorf("flag secret cannot change during the game")
+	}
+	for i, q := range game.list {
+		if !ranking.Earned(i) {
+			continue
+		}
+		if i >= len(g.list) {
+			return fmt.Errorf("question %d has scores and cannot be removed", i+1)
+		}
+		ow, oh := q.Size()
+		nw, nh := g.list[i].Size()
+		if ow != nw || oh != nh {
+			return fmt.Errorf("question %d has scores and cannot change its size from %d*%d to %d*%d", i+1, ow, oh, nw, nh)
+		}
+	}
+	return nil
+}
+
+// watchReload reloads the config at path on every SIGHUP.
+func watchReload(path string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		if err := reloadConfig(path); err != nil {
+			logger.Warn("config reload rejected", "path", path, "error", err)
+		}
+	}
+}
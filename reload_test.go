@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const reloadConfigYAML = `questions:
+  - flag: "SECCON{1}"
+    map: "01 10"
+teams:
+  - name: a
+    address: "192.168.1."
+  - name: b
+    address: "192.168.2."
+game:
+  start: "2026-10-19T09:00:00Z"
+  interval: INTERVAL
+admin:
+  token: "k7Qw9zX2pL4mN8vR"
+`
+
+func TestReloadConfig(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10", Flag: "SECCON{1}"})
+	write := func(interval, m string) {
+		y := strings.Replace(reloadConfigYAML, "INTERVAL", interval, 1)
+		y = strings.Replace(y, "01 10", m, 1)
+		if err := os.WriteFile("config.yaml", []byte(y), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w := serve(r, "192.168.1.1", "POST", "/api/v1/answer/1", "", gzipped("01\n10\n")); w.Code != http.StatusOK {
+		t.Fatalf("answer: got %d %s", w.Code, w.Body)
+	}
+	next := iBreaker.Next("a")
+
+	write("5", "01 10")
+	if err := reloadConfig("config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if config.Game.Interval != 5 || game.Interval(clock.Now(), config.Game.Interval) != 5*time.Second {
+		t.Errorf("the new config must be swapped in, interval is %v", config.Game.Interval)
+	}
+	if leader, ok := ranking.Leader(); !ok || leader.Name != "a" || leader.TotalScore != 4 {
+		t.Errorf("the ranking must be kept: %+v", leader)
+	}
+	if !iBreaker.Next("a").Add(-4 * time.Second).Equal(next) {
+		t.Errorf("the limiter state must be kept: next request at %v, was %v", iBreaker.Next("a"), next)
+	}
+
+	write("1", "011 100")
+	if err := reloadConfig("config.yaml"); err == nil || !strings.Contains(err.Error(), "cannot change its size") {
+		t.Errorf("a size change of a scored question must be rejected, got %v", err)
+	}
+	if config.Game.Interval != 5 {
+		t.Error("a rejected config must not be swapped in")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrUnknownRound = errors.New("unknown round")
+
+	errUnknownRound = &APIError{http.StatusNotFound, "unknown_round", "unknown round"}
+)
+
+// Round is a stage of the game with its own questions, rate limit and
+// scoring multiplier.
+type Round struct {
+	name       string
+	start, end time.Duration
+	// questions holds the indexes of the questions of the round.
+	questions  map[int]bool
+	interval   float64
+	multiplier float64
+}
+
+// newRounds lays out rcs over the game from start to end. A round without
+// an end lasts until the next one starts or the game ends.
+func newRounds(rcs []RoundConfig, start, end time.Time) []Round {
+	rounds := make([]Round, len(rcs))
+	for i, rc := range rcs {
+		r := Round{
+			name:       rc.Name,
+			start:      time.Duration(rc.Start) * time.Second,
+			end:        time.Duration(rc.End) * time.Second,
+			questions:  make(map[int]bool, len(rc.Questions)),
+			interval:   rc.Interval,
+			multiplier: rc.Multiplier,
+		}
+		if r.end == 0 && i+1 < len(rcs) {
+			r.end = time.Duration(rcs[i+1].Start) * time.Second
+		}
+		if r.end == 0 && !end.IsZero() {
+			r.end = end.Sub(start)
+		}
+		if r.multiplier <= 0 {
+			r.multiplier = 1
+		}
+		for _, n := range rc.Questions {
+			r.questions[n-1] = true
+		}
+		rounds[i] = r
+	}
+	return rounds
+}
+
+// roundAt returns the index of the last round started by now, -1 before
+// the first one, and whether it is still running. A round started by hand
+// stays current until the schedule moves past it.
+func (g *Game) roundAt(now time.Time) (int, bool) {
+	idx := -1
+	for i, r := range g.rounds {
+		if !now.Before(g.start.Add(r.start)) {
+			idx = i
+		}
+	}
+	for i, r := range g.rounds {
+		if r.name == g.forced && i >= idx {
+			return i, true
+		}
+	}
+	if idx < 0 {
+		return idx, false
+	}
+	r := g.rounds[idx]
+	return idx, r.end == 0 || now.Before(g.start.Add(r.end))
+}
+
+// currentRound returns the name of the round running at now, empty when
+// there is none.
+func (g *Game) currentRound(now time.Time) string {
+	idx, active := g.roundAt(now)
+	if !active {
+		return ""
+	}
+	return g.rounds[idx].name
+}
+
+// roundStatus closes question number outside of its rounds. It is empty
+// when the schedule of the question decides.
+func (g *Game) roundStatus(number int, now time.Time) string {
+	idx, active := g.roundAt(now)
+	state := ""
+	for i, r := range g.rounds {
+		if !r.questions[number] {
+			continue
+		}
+		switch {
+		case i == idx && active:
+			return ""
+		case i > idx:
+			state = "upcoming"
+		case state == "":
+			state = "closed"
+		}
+	}
+	return state
+}
+
+// Interval returns the rate limit of the round running at now, def
+// seconds when the round has none of its own.
+func (g *Game) Interval(now time.Time, def float64) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if idx, active := g.roundAt(now); active && g.rounds[idx].interval > 0 {
+		def = g.rounds[idx].interval
+	}
+	return time.Duration(def * float64(time.Second))
+}
+
+// StartRound makes the round name current until the schedule moves past
+// it. An empty name returns the rounds to their schedule.
+func (g *Game) StartRound(name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if name == "" {
+		g.forced = ""
+		return nil
+	}
+	for _, r := range g.rounds {
+		if r.name == name {
+			g.forced = name
+			return nil
+		}
+	}
+	return ErrUnknownRound
+}
+
+// AdvanceRound reports the round left and the round entered since the
+// last call.
+func (g *Game) AdvanceRound(now time.Time) (from, to string, changed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	to = g.currentRound(now)
+	if to == g.seen {
+		return "", "", false
+	}
+	from, g.seen = g.seen, to
+	return from, to, true
+}
+
+// RoundInfo is what players may know about a round.
+type RoundInfo struct {
+	Name       string     `json:"name"`
+	Start      time.Time  `json:"start"`
+	End        *time.Time `json:"end,omitempty"`
+	Questions  []int      `json:"questions"`
+	Interval   float64    `json:"interval,omitempty"`
+	Multiplier float64    `json:"multiplier"`
+	// Status is "upcoming", "current" or "over".
+	Status  string      `json:"status"`
+	Ranking []RoundRank `json:"ranking,omitempty"`
+}
+
+// Rounds describes every round as of now.
+func (g *Game) Rounds(now time.Time) []RoundInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	idx, active := g.roundAt(now)
+	list := make([]RoundInfo, 0, len(g.rounds))
+	for i, r := range g.rounds {
+		info := RoundInfo{
+			Name:       r.name,
+			Start:      g.start.Add(r.start),
+			Questions:  []int{},
+			Interval:   r.interval,
+			Multiplier: r.multiplier,
+			Status:     "upcoming",
+		}
+		if r.end != 0 {
+			end := g.start.Add(r.end)
+			info.End = &end
+		}
+		for n := range r.questions {
+			info.Questions = append(info.Questions, n+1)
+		}
+		sort.Ints(info.Questions)
+		switch {
+		case i == idx && active:
+			info.Status = "current"
+		case i <= idx:
+			info.Status = "over"
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
+// advanceRound follows the rounds at now: it applies the rate limit of
+// the round entered and snapshots the ranking of the round left.
+func advanceRound(now time.Time, remote string) {
+	from, to, changed := game.AdvanceRound(now)
+	if !changed {
+		return
+	}
+	iBreaker.SetDuration(game.Interval(now, config.Game.Interval))
+	if from != "" {
+		if err := ranking.SnapshotRound(from); err != nil {
+			logger.Error("ranking save", "error", err)
+		}
+	}
+	logger.Info("round changed", "from", from, "to", to)
+	audit.Record(remote, "round changed", to, "", "from "+from)
+}
+
+// roundsWithRanking adds the ranking of each round to infos, the
+// snapshot for rounds that are over.
+func roundsWithRanking(infos []RoundInfo) []RoundInfo {
+	for i := range infos {
+		infos[i].Ranking = ranking.RoundRanking(infos[i].Name, infos[i].Status == "over")
+	}
+	return infos
+}
+
+// current returns the current round of infos, nil between rounds.
+func current(infos []RoundInfo) *RoundInfo {
+	for i := range infos {
+		if infos[i].Status == "current" {
+			return &infos[i]
+		}
+	}
+	return nil
+}
+
+func apiRounds(c *gin.Context) {
+	c.JSON(http.StatusOK, roundsWithRanking(game.Rounds(clock.Now())))
+}
+
+func adminRoundStart(c *gin.Context) {
+	req := adminRequest(c)
+	if err := game.StartRound(c.Param("name")); err != nil {
+		adminError(c, errUnknownRound)
+		return
+	}
+	advanceRound(clock.Now(), getIpAddr(c.Request))
+	adminDone(c, "round start", c.Param("name"), req.Reason)
+}
+
+func adminRoundSchedule(c *gin.Context) {
+	req := adminRequest(c)
+	game.StartRound("")
+	advanceRound(clock.Now(), getIpAddr(c.Request))
+	adminDone(c, "round schedule", "", req.Reason)
+}
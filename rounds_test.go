@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRounds(t *testing.T) {
+	start := time.Now().Add(-30 * time.Minute)
+	qs := []QuestionConfig{{Map: "01 10"}, {Map: "01 10"}, {Map: "01 10"}}
+	rounds := []RoundConfig{
+		{Name: "qual", Start: 0, Questions: []int{1}},
+		{Name: "final", Start: 3600, Questions: []int{2}, Interval: 5, Multiplier: 2},
+	}
+	g, err := NewGame(realClock{}, start, start.Add(2*time.Hour), qs, rounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := start.Add(10 * time.Minute)
+	for n, want := range []string{"open", "upcoming", "open"} {
+		if got := g.status(n, now); got != want {
+			t.Errorf("question %d is %s in qual, want %s", n+1, got, want)
+		}
+	}
+	if g.Interval(now, 1) != time.Second {
+		t.Error("qual must keep the game interval")
+	}
+
+	later := start.Add(90 * time.Minute)
+	if g.status(0, later) != "closed" || g.status(1, later) != "open" {
+		t.Error("questions must follow their rounds")
+	}
+	if g.Interval(later, 1) != 5*time.Second {
+		t.Error("final must have its own interval")
+	}
+
+	if err := g.StartRound("final"); err != nil {
+		t.Fatal(err)
+	}
+	from, to, changed := g.AdvanceRound(now)
+	if !changed || from != "qual" || to != "final" {
+		t.Errorf("got %q -> %q, %v", from, to, changed)
+	}
+	if g.status(1, now) != "open" {
+		t.Error("a round started by hand must open its questions")
+	}
+	if _, _, changed := g.AdvanceRound(now); changed {
+		t.Error("the round must change once")
+	}
+	if g.StartRound("semi") != ErrUnknownRound {
+		t.Error("unknown rounds must be refused")
+	}
+}
+
+func TestRoundScores(t *testing.T) {
+	rounds := []RoundConfig{
+		{Name: "qual", Questions: []int{1}},
+		{Name: "final", Questions: []int{2}, Multiplier: 2},
+	}
+	rb := NewRankingBoard(realClock{}, time.Now(), make([]QuestionConfig, 2), rounds)
+	rb.List["a"] = RankingItem{Name: "a", Score: []int{10, 3}}
+	rb.List["b"] = RankingItem{Name: "b", Score: []int{4, 6}}
+	if rb.total(rb.List["a"]) != 16 || rb.total(rb.List["b"]) != 16 {
+		t.Error("final must count twice")
+	}
+
+	qual := rb.RoundRanking("qual", false)
+	if qual[0].Name != "a" || qual[0].Score != 10 || qual[1].Rank != 2 {
+		t.Errorf("unexpected qual ranking %+v", qual)
+	}
+	rb.RoundScores["final"] = map[string]int{"a": 100}
+	if final := rb.RoundRanking("final", true); len(final) != 1 || final[0].Score != 100 {
+		t.Errorf("the snapshot must be used, got %+v", final)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pipeListener is an in-process listener. Each connection claims the
+// address it was dialed from, so that the server tells synthetic teams
+// apart.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+// Dial connects to the listener from ip.
+func (l *pipeListener) Dial(ctx context.Context, ip net.IP) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- &addrConn{server, &net.TCPAddr{IP: ip, Port: 1024}}:
+		return client, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// latencies records how long the requests of every team took.
+type latencies struct {
+	mu   sync.Mutex
+	list []time.Duration
+}
+
+type timedTransport struct {
+	http.RoundTripper
+	l *latencies
+}
+
+func (t timedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.RoundTripper.RoundTrip(r)
+	t.l.mu.Lock()
+	t.l.list = append(t.l.list, time.Since(start))
+	t.l.mu.Unlock()
+	return resp, err
+}
+
+// simTeam is a synthetic team of the simulation.
+type simTeam struct {
+	name     string
+	strategy string
+	// wait is the game time between two requests.
+	wait   time.Duration
+	client *Client
+	states map[int]*SolverState
+	// flags holds the game time from the opening of each question until
+	// the team got its flag.
+	flags map[int]time.Duration
+	err   error
+}
+
+// run solves the questions as they open until none is left or the game
+// ends at end.
+func (t *simTeam) run(end time.Time, block int) {
+	done := map[int]bool{}
+	for clock.Now().Before(end) {
+		infos, err := t.client.Questions()
+		if err != nil {
+			t.err = err
+			return
+		}
+		left := false
+		for _, info := range infos {
+			if done[info.Number] || info.Status == "closed" {
+				continue
+			}
+			if info.Status != "open" {
+				left = true
+				continue
+			}
+			s := newSolverState(info, t.strategy, block)
+			t.states[info.Number] = s
+			err := t.client.solve(s, info.Batch, 0, func() error {
+				if _, ok := t.flags[info.Number]; !ok && s.Flag != "" {
+					t.flags[info.Number] = clock.Now().Sub(info.Open)
+				}
+				return nil
+			})
+			// a question closing is done
+			var e *APIError
+			if err != nil && !errors.As(err, &e) {
+				t.err = err
+				return
+			}
+			done[info.Number] = true
+		}
+		if !left {
+			return
+		}
+		time.Sleep(max(t.client.Wait, 10*time.Millisecond))
+	}
+}
+
+// cmdSimulate rehearses the game of the config on an in-process server
+// with synthetic teams, scale times faster than real time, and reports the
+// ranking, the SLA, the time to flag and the latency.
+func cmdSimulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	n := fs.Int("teams", 6, "number of synthetic teams")
+	strategies := fs.String("strategies", "adaptive,block,pixel", "solver strategies, given to the teams in turn")
+	waits := fs.String("waits", "1,2,5", "seconds between the requests of a team, given to the teams in turn")
+	block := fs.Int("block", 8, "side of the blocks of the block and adaptive strategies")
+	encoding := fs.String("encoding", "batch", "text, json, batch or multipart")
+	scale := fs.Float64("scale", 60, "how many times faster than real time the game runs")
+	duration := fs.Duration("duration", 0, "length of the game, from the config when 0")
+	fs.Parse(args)
+
+	d := *duration
+	if d == 0 && !config.Game.End.IsZero() {
+		d = config.Game.End.Sub(config.Game.Start)
+	}
+	var waitList []time.Duration
+	for _, w := range strings.Split(*waits, ",") {
+		s, err := strconv.ParseFloat(w, 64)
+		if err != nil || s < 0 {
+			waitList = nil
+			break
+		}
+		waitList = append(waitList, time.Duration(s*float64(time.Second)))
+	}
+	stratList := strings.Split(*strategies, ",")
+	for _, s := range stratList {
+		if s != "pixel" && s != "block" && s != "adaptive" {
+			stratList = nil
+		}
+	}
+	if d <= 0 || *scale <= 0 || *n < 1 || *block < 1 || len(waitList) == 0 || len(stratList) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: simulate [flags], the game needs an end or -duration")
+		fs.PrintDefaults()
+		return 2
+	}
+	if _, _, err := encodeAnswer(*encoding, []Map{{}}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	tmpl, err := loadTemplates(config.Theme.Dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// the backups and the audit log of the rehearsal go to a scratch
+	// directory
+	wd, _ := os.Getwd()
+	dir, err := os.MkdirTemp("", "findimage-simulate")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	// the game starts now and runs on a clock scale times faster
+	c := *config
+	if c.Game.Start.IsZero() {
+		c.Game.Start = time.Now()
+	}
+	c.Game.End = c.Game.Start.Add(d)
+	config = &c
+	clock = newClock(*scale, time.Until(c.Game.Start))
+	start, end := time.Now(), c.Game.End
+	tcs := make([]TeamConfig, *n)
+	for i := range tcs {
+		tcs[i] = TeamConfig{Name: fmt.Sprintf("sim%d", i+1), Address: fmt.Sprintf("10.0.%d.", i+1)}
+	}
+	config.Teams = tcs
+	setTeams(tcs)
+	logger, _ = newLogger("error", "stderr")
+	if err := setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	gin.SetMode(gin.ReleaseMode)
+	l := newPipeListener()
+	srv := &http.Server{Handler: newRouter(tmpl)}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	// the clock of the server ticks and the leader is sampled every
+	// second of game time
+	var mu sync.Mutex
+	sla, samples := map[string]int{}, 0
+	stop := make(chan struct{})
+	go func() {
+		t := time.NewTicker(max(time.Duration(float64(time.Second) / *scale), time.Millisecond))
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				tick(clock.Now())
+				mu.Lock()
+				if leader, ok := ranking.Leader(); ok {
+					sla[leader.Name]++
+				}
+				samples++
+				mu.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	lat := &latencies{}
+	teams := make([]*simTeam, *n)
+	var wg sync.WaitGroup
+	for i, tc := range tcs {
+		ip := net.ParseIP(tc.Address + "1")
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return l.Dial(ctx, ip)
+			},
+		}
+		wait := waitList[i%len(waitList)]
+		teams[i] = &simTeam{
+			name:     tc.Name,
+			strategy: stratList[i%len(stratList)],
+			wait:     wait,
+			client: &Client{
+				Server:   "http://simulation",
+				Encoding: *encoding,
+				HTTP:     &http.Client{Transport: timedTransport{transport, lat}},
+				Wait:     time.Duration(float64(wait) / *scale),
+			},
+			states: map[int]*SolverState{},
+			flags:  map[int]time.Duration{},
+		}
+		wg.Add(1)
+		go func(t *simTeam) {
+			defer wg.Done()
+			t.run(end, *block)
+		}(teams[i])
+	}
+	wg.Wait()
+	close(stop)
+
+	elapsed := time.Since(start)
+	mu.Lock()
+	defer mu.Unlock()
+	report(teams, sla, samples, lat.list, elapsed, *scale)
+	return 0
+}
+
+// report prints how the simulation went.
+func report(teams []*simTeam, sla map[string]int, samples int, lat []time.Duration, elapsed time.Duration, scale float64) {
+	byName := map[string]*simTeam{}
+	requests := 0
+	for _, t := range teams {
+		byName[t.name] = t
+		for _, s := range t.states {
+			requests += s.Requests
+		}
+		if t.err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", t.name, t.err)
+		}
+	}
+	fmt.Printf("simulated %s of game in %s, %d teams, %d requests\n\n",
+		time.Duration(float64(elapsed)*scale).Round(time.Second), elapsed.Round(time.Millisecond), len(teams), requests)
+
+	fmt.Println("ranking")
+	for _, row := range ranking.Scoreboard(game.Info(clock.Now())) {
+		t := byName[row.Name]
+		fmt.Printf("  %2d  %-6s %-8s every %-4s %8d\n", row.Rank, row.Name, t.strategy, t.wait, row.Total)
+	}
+
+	fmt.Println("\nSLA, share of the game in 1st")
+	for _, t := range teams {
+		if samples > 0 && sla[t.name] > 0 {
+			fmt.Printf("  %-6s %5.1f%%\n", t.name, 100*float64(sla[t.name])/float64(samples))
+		}
+	}
+
+	fmt.Println("\ntime to flag from the opening")
+	for i := range game.list {
+		times := []time.Duration{}
+		first := ""
+		for _, t := range teams {
+			d, ok := t.flags[i+1]
+			if !ok {
+				continue
+			}
+			if first == "" || d < byName[first].flags[i+1] {
+				first = t.name
+			}
+			times = append(times, d)
+		}
+		if len(times) == 0 {
+			fmt.Printf("  question %d: no flag\n", i+1)
+			continue
+		}
+		sort.Slice(times, func(a, b int) bool { return times[a] < times[b] })
+		fmt.Printf("  question %d: %d of %d teams, first %s (%s), median %s\n", i+1, len(times), len(teams),
+			times[0].Round(time.Second), first, times[len(times)/2].Round(time.Second))
+	}
+
+	if len(lat) > 0 {
+		sort.Slice(lat, func(a, b int) bool { return lat[a] < lat[b] })
+		at := func(p float64) time.Duration {
+			return lat[int(p*float64(len(lat)-1))].Round(time.Microsecond)
+		}
+		fmt.Printf("\nlatency of %d requests: p50 %s, p95 %s, p99 %s, max %s\n", len(lat), at(0.5), at(0.95), at(0.99), at(1))
+	}
+}
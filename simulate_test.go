@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestPipeListener(t *testing.T) {
+	l := newPipeListener()
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getIpAddr(r)))
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return l.Dial(ctx, net.ParseIP("10.0.3.1"))
+		},
+	}}
+	resp, err := client.Get("http://simulation/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 16)
+	n, _ := resp.Body.Read(buf)
+	if string(buf[:n]) != "10.0.3.1" {
+		t.Errorf("unexpected address %q", buf[:n])
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stats counts what teams do, for organizers and for the teams themselves.
+type Stats struct {
+	Teams map[string]*TeamStats
+	mu    *sync.Mutex
+}
+
+type TeamStats struct {
+	// Attempts and BestWrong are per question, BestWrong is -1 until the
+	// first scored attempt.
+	Attempts    []int
+	BestWrong   []int
+	LastRequest time.Time
+	RateLimited int
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		Teams: make(map[string]*TeamStats),
+		mu:    &sync.Mutex{},
+	}
+}
+
+// Request records that team sent a request now.
+func (s *Stats) Request(team string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.team(team).LastRequest = clock.Now()
+}
+
+// RateLimit records that a request of team was turned down by the limiter.
+func (s *Stats) RateLimit(team string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.team(team).RateLimited++
+	metricRateLimited.WithLabelValues(team).Inc()
+}
+
+// Attempt records a scored candidate of team for question number.
+func (s *Stats) Attempt(team string, number, wrong int) {
+	metricAnswers.WithLabelValues(strconv.Itoa(number+1), team).Inc()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.team(team)
+	for len(ts.Attempts) <= number {
+		ts.Attempts = append(ts.Attempts, 0)
+		ts.BestWrong = append(ts.BestWrong, -1)
+	}
+	ts.Attempts[number]++
+	if ts.BestWrong[number] < 0 || wrong < ts.BestWrong[number] {
+		ts.BestWrong[number] = wrong
+	}
+}
+
+// Get returns a copy of the stats of team, padded to questions.
+func (s *Stats) Get(team string, questions int) TeamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := TeamStats{
+		Attempts:  make([]int, questions),
+		BestWrong: make([]int, questions),
+	}
+	for i := range ts.BestWrong {
+		ts.BestWrong[i] = -1
+	}
+	if t, ok := s.Teams[team]; ok {
+		copy(ts.Attempts, t.Attempts)
+		copy(ts.BestWrong, t.BestWrong)
+		ts.LastRequest = t.LastRequest
+		ts.RateLimited = t.RateLimited
+	}
+	return ts
+}
+
+// Names returns every team seen so far.
+func (s *Stats) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.Teams))
+	for name := range s.Teams {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *Stats) team(name string) *TeamStats {
+	ts, ok := s.Teams[name]
+	if !ok {
+		ts = &TeamStats{}
+		s.Teams[name] = ts
+	}
+	return ts
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStatsAttempt(t *testing.T) {
+	s := NewStats()
+	s.Attempt("nw", 1, 30)
+	s.Attempt("nw", 1, 10)
+	s.Attempt("nw", 1, 20)
+	s.RateLimit("nw")
+
+	ts := s.Get("nw", 3)
+	if ts.Attempts[1] != 3 || ts.Attempts[0] != 0 || ts.Attempts[2] != 0 {
+		t.Errorf("unexpected attempts: %v", ts.Attempts)
+	}
+	if ts.BestWrong[1] != 10 || ts.BestWrong[0] != -1 || ts.BestWrong[2] != -1 {
+		t.Errorf("unexpected best wrong counts: %v", ts.BestWrong)
+	}
+	if ts.RateLimited != 1 {
+		t.Errorf("unexpected rate limit hits: %d", ts.RateLimited)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+)
+
+// assets holds the default templates and styles, a theme directory laid
+// out the same way may override any of them.
+//
+//go:embed templates/*.html css
+var assets embed.FS
+
+// themeFS looks up files in dir first and falls back to the embedded
+// assets.
+type themeFS struct {
+	dir string
+}
+
+func (t themeFS) Open(name string) (fs.File, error) {
+	if t.dir != "" {
+		f, err := os.DirFS(t.dir).Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return assets.Open(name)
+}
+
+// loadTemplates parses every template of the theme in dir.
+func loadTemplates(dir string) (*template.Template, error) {
+	names, err := fs.Glob(assets, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		extra, err := fs.Glob(os.DirFS(dir), "templates/*.html")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, extra...)
+	}
+
+	theme := themeFS{dir: dir}
+	tmpl := template.New("html").Funcs(template.FuncMap{
+		"t":          translate,
+		"languages":  languages,
+		"pathescape": url.PathEscape,
+	})
+	for _, name := range names {
+		if tmpl.Lookup(path.Base(name)) != nil {
+			continue
+		}
+		buf, err := fs.ReadFile(theme, name)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err = tmpl.New(path.Base(name)).Parse(string(buf))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// themeCSS returns the styles of the theme in dir.
+func themeCSS(dir string) fs.FS {
+	css, err := fs.Sub(themeFS{dir: dir}, "css")
+	if err != nil {
+		panic(err)
+	}
+	return css
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	tmpl, err := loadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Lookup("index.html") == nil || tmpl.Lookup("admin.html") == nil {
+		t.Fatal("embedded templates are missing")
+	}
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "templates"), 0755)
+	os.MkdirAll(filepath.Join(dir, "css"), 0755)
+	os.WriteFile(filepath.Join(dir, "templates", "index.html"), []byte("branded"), 0644)
+	os.WriteFile(filepath.Join(dir, "css", "brand.css"), []byte("body{}"), 0644)
+
+	tmpl, err = loadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	tmpl.ExecuteTemplate(buf, "index.html", nil)
+	if buf.String() != "branded" {
+		t.Errorf("index.html is not overridden: %q", buf.String())
+	}
+	if tmpl.Lookup("admin.html") == nil {
+		t.Error("admin.html must fall back to the embedded one")
+	}
+
+	css := themeCSS(dir)
+	if _, err := fs.Stat(css, "brand.css"); err != nil {
+		t.Error(err)
+	}
+	buf.Reset()
+	b, err := fs.ReadFile(css, "github-markdown.css")
+	if err != nil || !strings.Contains(string(b), "markdown-body") {
+		t.Error("github-markdown.css must fall back to the embedded one")
+	}
+}
+
+func TestThemeDirRelative(t *testing.T) {
+	t.Setenv(envPrefix+"ADMINTOKEN", "0123456789abcdef0123")
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "theme", "templates"), 0700)
+	os.WriteFile(filepath.Join(dir, "theme", "templates", "me.html"), []byte(`{{define "me.html"}}themed{{end}}`), 0600)
+	os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`questions:
+  - flag: "SECCON{1}"
+    map: "01 10"
+game:
+  start: "2026-10-19T09:00:00Z"
+  interval: 1
+theme:
+  dir: theme
+`), 0600)
+
+	t.Chdir(t.TempDir())
+	c, err := readConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Theme.Dir != filepath.Join(dir, "theme") {
+		t.Errorf("theme dir is %q", c.Theme.Dir)
+	}
+	tmpl, err := loadTemplates(c.Theme.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	tmpl.ExecuteTemplate(buf, "me.html", nil)
+	if buf.String() != "themed" {
+		t.Errorf("the theme is dropped: %q", buf)
+	}
+}
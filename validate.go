@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// flagFormat is what flags look like, SECCON{...}.
+var flagFormat = regexp.MustCompile(`^[A-Za-z0-9_]+\{[^{}]+\}$`)
+
+// weakToken tells whether token is too short to guard /admin, which then
+// stays closed.
+func weakToken(token string) bool {
+	return len(token) < 16
+}
+
+// configWarnings lists settings of c that are accepted but turn a feature
+// off.
+func configWarnings(c *Config) []string {
+	warnings := []string{}
+	if weakToken(c.Admin.Token) {
+		warnings = append(warnings, "admin.token is empty or shorter than 16 characters, /admin is disabled; set it with "+envPrefix+"ADMINTOKEN")
+	}
+	return warnings
+}
+
+// Problem is an inconsistency of the config found at Line of File, 0
+// when the line is unknown.
+type Problem struct {
+	File    string
+	Line    int
+	Path    string
+	Message string
+}
+
+// ConfigError lists every problem of a config.
+type ConfigError struct {
+	Problems []Problem
+}
+
+func (e *ConfigError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		if p.Line == 0 {
+			lines[i] = fmt.Sprintf("%s: %s: %s", p.File, p.Path, p.Message)
+			continue
+		}
+		lines[i] = fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Path, p.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateConfig checks c which was read from s.
+func validateConfig(c *Config, s *configSource) []Problem {
+	problems := []Problem{}
+	report := func(offset int, msg string, path ...interface{}) {
+		file, line := s.locate(path...)
+		if line > 0 {
+			line += offset
+		}
+		// a missing key is reported where its parent is
+		for n := len(path) - 1; line == 0 && n > 0 && !strings.HasPrefix(file, "$"); n-- {
+			file, line = s.locate(path[:n]...)
+		}
+		problems = append(problems, Problem{file, line, yamlPath(path...), msg})
+	}
+
+	if c.Game.Start.IsZero() {
+		report(0, "start is missing", "game", "start")
+	}
+	if !c.Game.End.IsZero() && !c.Game.Start.Before(c.Game.End) {
+		report(0, "end must be after start", "game", "end")
+	}
+	if c.Game.Interval <= 0 {
+		report(0, "interval must be positive", "game", "interval")
+	}
+	duration := int(c.Game.End.Sub(c.Game.Start).Seconds())
+
+	if len(c.Questions) == 0 {
+		report(0, "no questions", "questions")
+	}
+	flags := map[string]int{}
+	for i, qc := range c.Questions {
+		validateMap(qc.Map, func(row int, msg string) {
+			if name := s.mapFile(i); name != "" {
+				problems = append(problems, Problem{name, row + 1, yamlPath("questions", i, "mapfile"), msg})
+				return
+			}
+			report(row, msg, "questions", i, "map")
+		})
+		switch {
+		case qc.Flag == "":
+			report(0, "flag is missing", "questions", i, "flag")
+		case !flagFormat.MatchString(qc.Flag):
+			report(0, "flag must look like SECCON{...}", "questions", i, "flag")
+		}
+		if n, ok := flags[qc.Flag]; ok && qc.Flag != "" {
+			report(0, fmt.Sprintf("flag is the same as the one of question %d", n+1), "questions", i, "flag")
+		}
+		flags[qc.Flag] = i
+		if qc.Open < 0 {
+			report(0, "open must not be negative", "questions", i, "open")
+		}
+		if duration > 0 && qc.Open >= duration {
+			report(0, "open is after the end of the game", "questions", i, "open")
+		}
+		if qc.Close != 0 && qc.Close <= qc.Open {
+			report(0, "close must be after open", "questions", i, "close")
+		}
+		if qc.Threshold < 0 || qc.Threshold >= 1 {
+			report(0, "threshold must be at least 0 and below 1", "questions", i, "threshold")
+		}
+		if qc.Batch < 0 || qc.BatchCost < 0 {
+			report(0, "batch and batchcost must not be negative", "questions", i)
+		}
+		for j, hc := range qc.Hints {
+			if hc.Text == "" && hc.Region == nil {
+				report(0, "hint has neither text nor region", "questions", i, "hints", j)
+			}
+			if hc.After < 0 {
+				report(0, "after must not be negative", "questions", i, "hints", j, "after")
+			}
+		}
+	}
+
+	names := map[string]bool{}
+	for i, rc := range c.Rounds {
+		if rc.Name == "" || names[rc.Name] {
+			report(0, "name is missing or used twice", "rounds", i, "name")
+		}
+		names[rc.Name] = true
+		if i > 0 && rc.Start < c.Rounds[i-1].Start {
+			report(0, "rounds must be in order of start", "rounds", i, "start")
+		}
+		if rc.Start < 0 || rc.End != 0 && rc.End <= rc.Start {
+			report(0, "start must not be negative and end must be after start", "rounds", i)
+		}
+		for _, n := range rc.Questions {
+			if n < 1 || n > len(c.Questions) {
+				report(0, fmt.Sprintf("question %d does not exist", n), "rounds", i, "questions")
+			}
+		}
+		if rc.Interval < 0 || rc.Multiplier < 0 {
+			report(0, "interval and multiplier must not be negative", "rounds", i)
+		}
+	}
+
+	for i, tc := range c.Teams {
+		if tc.Name == "" || tc.Name == unknownTeam {
+			report(0, "name is missing", "teams", i, "name")
+		}
+		if tc.Address == "" {
+			report(0, "address is missing", "teams", i, "address")
+			continue
+		}
+		for j, other := range c.Teams[:i] {
+			if strings.HasPrefix(tc.Address, other.Address) || strings.HasPrefix(other.Address, tc.Address) {
+				report(0, fmt.Sprintf("address overlaps with %q of team %s", other.Address, c.Teams[j].Name), "teams", i, "address")
+			}
+		}
+	}
+	return problems
+}
+
+// validateMap reports every row of m which differs in width from most
+// of the others or holds something else than 0 and 1.
+func validateMap(m string, report func(row int, msg string)) {
+	if m == "" {
+		report(0, "map is empty")
+		return
+	}
+	rows := strings.Split(m, " ")
+	widths := map[int]int{}
+	width := 0
+	for _, row := range rows {
+		widths[len(row)]++
+		if widths[len(row)] > widths[width] {
+			width = len(row)
+		}
+	}
+	for i, row := range rows {
+		if len(row) != width {
+			report(i, fmt.Sprintf("row %d has %d characters, %d expected", i+1, len(row), width))
+		}
+		if strings.Trim(row, "01") != "" {
+			report(i, fmt.Sprintf("row %d has characters other than 0 and 1", i+1))
+		}
+	}
+}
+
+// yamlPath formats path like questions[1].map.
+func yamlPath(path ...interface{}) string {
+	s := ""
+	for _, p := range path {
+		switch p := p.(type) {
+		case int:
+			s += "[" + strconv.Itoa(p) + "]"
+		case string:
+			if s != "" {
+				s += "."
+			}
+			s += p
+		}
+	}
+	return s
+}
+
+// yamlLine finds the line of path in the YAML src, 0 when it is not
+// there. path holds keys and list indexes.
+func yamlLine(src []byte, path ...interface{}) int {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil || len(doc.Content) == 0 {
+		return 0
+	}
+	n, line := doc.Content[0], 0
+	for _, p := range path {
+		for n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+		var next *yaml.Node
+		switch p := p.(type) {
+		case string:
+			if n.Kind != yaml.MappingNode {
+				return 0
+			}
+			for i := 0; i+1 < len(n.Content) && next == nil; i += 2 {
+				if n.Content[i].Value == p {
+					line, next = n.Content[i].Line, n.Content[i+1]
+				}
+			}
+		case int:
+			if n.Kind != yaml.SequenceNode || p < 0 || p >= len(n.Content) {
+				return 0
+			}
+			next = n.Content[p]
+			line = next.Line
+		}
+		if next == nil {
+			return 0
+		}
+		n = next
+	}
+	return line
+}
+
+// cmdValidate checks the config at path and prints its problems.
+func cmdValidate(path string) int {
+	c, err := readConfig(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, w := range configWarnings(c) {
+		fmt.Fprintln(os.Stderr, path+": warning: "+w)
+	}
+	fmt.Println(path + ": ok")
+	return 0
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const invalidConfig = `# broken on purpose
+questions:
+  - open: 0
+    flag: "SECCON{1}"
+    map: "010
+    0110
+    010"
+  - flag: "SECCON{1}"
+    map: "1"
+    hints:
+      - text: "late"
+        after: -5
+teams:
+- name: a
+  address: "10.0.1."
+- name: b
+  address: "10.0.1.2"
+game:
+  start: "2016-01-31T11:00:00.0+09:00"
+  end: "2016-01-31T10:00:00.0+09:00"
+  interval: 1
+`
+
+func TestValidateConfig(t *testing.T) {
+	c := &Config{}
+	if err := yaml.Unmarshal([]byte(invalidConfig), c); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"questions[0].map":            6,
+		"questions[1].flag":           8,
+		"questions[1].hints[0].after": 12,
+		"teams[1].address":            17,
+		"game.end":                    20,
+	}
+	problems := validateConfig(c, &configSource{file: "test.yaml", src: []byte(invalidConfig)})
+	for _, p := range problems {
+		line, ok := want[p.Path]
+		if !ok {
+			t.Errorf("unexpected problem %+v", p)
+			continue
+		}
+		if p.Line != line {
+			t.Errorf("%s is reported at line %d, want %d", p.Path, p.Line, line)
+		}
+		delete(want, p.Path)
+	}
+	for path := range want {
+		t.Errorf("%s is not reported", path)
+	}
+}
+
+const styledConfig = `defaults: &question
+  open: 0
+  threshold: 2
+questions:
+  - <<: *question
+    flag: 'SECCON{1}'
+    map: >-
+      01
+      10
+  - {flag: "nope", map: "01 10"}
+  - map: "01 10"
+    flag: |
+      SECCON{3}
+teams: [{name: a, address: "10.0.1."},
+  {name: b, address: "10.0.1.2"}]
+game: {start: "2016-01-31T11:00:00.0+09:00", interval: 1}
+`
+
+func TestValidateConfigStyles(t *testing.T) {
+	c := &Config{}
+	if err := yaml.Unmarshal([]byte(styledConfig), c); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"questions[0].threshold": 5,
+		"questions[1].flag":      10,
+		"questions[2].flag":      12,
+		"teams[1].address":       15,
+	}
+	problems := validateConfig(c, &configSource{file: "test.yaml", src: []byte(styledConfig)})
+	for _, p := range problems {
+		line, ok := want[p.Path]
+		if !ok {
+			t.Errorf("unexpected problem %+v", p)
+			continue
+		}
+		if p.Line != line {
+			t.Errorf("%s is reported at line %d, want %d", p.Path, p.Line, line)
+		}
+		delete(want, p.Path)
+	}
+	for path := range want {
+		t.Errorf("%s is not reported", path)
+	}
+}
+
+func TestValidateExamples(t *testing.T) {
+	for _, path := range []string{"config_example.yaml", "example/config.yaml"} {
+		c, err := readConfig(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if w := configWarnings(c); len(w) != 1 {
+			t.Errorf("%s: the empty admin token must be warned about: %q", path, w)
+		}
+	}
+}
@@ -6,89 +6,231 @@ import (
 	"encoding/json"
 	//"fmt"
 	"github.com/gin-gonic/gin"
-	"io/ioutil"
+	"io"
+	"math"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+type indexData struct {
+	Lang      string
+	Now       time.Time
+	Start     time.Time
+	End       time.Time
+	Ranking   []ScoreboardRow
+	Questions []QuestionInfo
+	Rounds    []RoundInfo
+	Round     *RoundInfo
+	Interval  float64
+}
+
 func viewIndex(c *gin.Context) {
-	list := ranking.Get()
-	c.HTML(http.StatusOK, "index.html", list)
+	now := clock.Now()
+	start, end := game.Period()
+	infos := game.Info(now)
+	rounds := game.Rounds(now)
+	c.HTML(http.StatusOK, "index.html", indexData{
+		Lang:      requestLang(c.Request),
+		Now:       now,
+		Start:     start,
+		End:       end,
+		Ranking:   ranking.Scoreboard(infos),
+		Questions: infos,
+		Rounds:    rounds,
+		Round:     current(rounds),
+		Interval:  game.Interval(now, config.Game.Interval).Seconds(),
+	})
 	return
 }
 
 func viewTeamflag(c *gin.Context) {
-	flag := getSLAFlag(ranking.SLA())
+	ipaddr := getIpAddr(c.Request)
+	team := Ip2Team(ipaddr)
+	lang := requestLang(c.Request)
+	if team == unknownTeam {
+		c.String(http.StatusForbidden, translate(lang, "teamflag.unknown"))
+		return
+	}
+	if bans.Banned(team) {
+		c.String(http.StatusForbidden, translate(lang, "teamflag.banned"))
+		return
+	}
+	leader, ok := ranking.Leader()
+	if !ok || leader.Name != team {
+		c.String(http.StatusForbidden, translate(lang, "teamflag.not_first"))
+		return
+	}
+	flag := getSLAFlag(leader.IpAddress)
+	requestLogger(c.Request).Info("SLA flag disclosed", "team", team, "remote", ipaddr, "flag", flag)
+	audit.Record(ipaddr, "SLA flag disclosed", team, "", flag)
 	c.String(http.StatusOK, flag)
 	return
 }
 
 func viewAnswer(c *gin.Context) {
-	ipaddr := getIpAddr(c.Request)
-	if !iBreaker.Check(ipaddr) {
+	resp, err := submitAnswer(getIpAddr(c.Request), c.Param("number"), c.Request)
+	if err != nil {
+		retryAfter(c, err)
 		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"message": "request is too many.",
+			"message": err.localize(requestLang(c.Request)).Message,
 		})
 		return
 	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	gzipr, err := gzip.NewReader(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"message": "gzip error",
-		})
+// retryAfter tells a rate limited client in the Retry-After header how
+// many seconds to wait.
+func retryAfter(c *gin.Context, err *APIError) {
+	if err.Code != errRateLimited.Code {
 		return
 	}
-	defer gzipr.Close()
-	defer c.Request.Body.Close()
+	wait := realDuration(iBreaker.Next(Ip2Team(getIpAddr(c.Request))).Sub(clock.Now()))
+	if wait < 0 {
+		wait = 0
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
 
-	buf, err := ioutil.ReadAll(gzipr)
+// submitAnswer scores the candidate image in r against question param
+// on behalf of the team at ipaddr.
+func submitAnswer(ipaddr, param string, r *http.Request) (*AnswerResponse, *APIError) {
+	team := Ip2Team(ipaddr)
+	stats.Request(team)
+	if bans.Banned(team) {
+		return nil, errBanned
+	}
+	if !iBreaker.Check(ipaddr) {
+		stats.RateLimit(team)
+		requestLogger(r).Info("rate limited", "team", team)
+		return nil, errRateLimited
+	}
+	requestLogger(r).Debug("rate limit passed", "team", team)
+
+	number, err := strconv.Atoi(param)
+	number -= 1
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"message": "gzip reading error",
-		})
-		return
+		return nil, errUnknownQuestion
 	}
-	tryMap, err := parseMapString(string(buf), "\r\n")
+	q, err := game.Question(number)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"message": "format error.(" + err.Error() + ")",
-		})
-		return
+		return nil, toAPIError(err)
 	}
 
-	number, err := strconv.Atoi(c.Param("number"))
-	number -= 1
+	tryMap, err := readAnswer(r, q)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"message": err.Error(),
-		})
-		return
+		apiErr := toAPIError(err)
+		metricParseFailures.WithLabelValues(apiErr.Code).Inc()
+		if apiErr.Code == errTooLarge.Code {
+			requestLogger(r).Warn("abuse", "team", team, "remote", ipaddr, "question", number+1, "error", apiErr.Message)
+		}
+		return nil, apiErr
 	}
 
 	score, wrong, flag, err := game.Try(tryMap, number)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"message": err.Error(),
-		})
-		return
+		return nil, toAPIError(err)
+	}
+	stats.Attempt(team, number, wrong)
+	requestLogger(r).Info("answer", "team", team, "question", number+1, "wrong", wrong, "score", score)
+	return &AnswerResponse{
+		Wrong: wrong,
+		Score: score,
+		Flag:  credit(r, ipaddr, number, score, flag, tryMap),
+	}, nil
+}
+
+// credit records score of the team at ipaddr on the ranking and returns
+// the flag issued to it, if any.
+func credit(r *http.Request, ipaddr string, number, score int, flag string, answer Map) string {
+	if flag != "" {
+		team := Ip2Team(ipaddr)
+		var first bool
+		flag, first = issuer.Issue(team, number, flag)
+		requestLogger(r).Info("flag issued", "team", team, "question", number+1, "first", first, "flag", flag)
+		if first {
+			audit.Record(ipaddr, "flag issued", team, "", flag)
+		}
+	}
+	improved, rankup, beFst := ranking.Append(ipaddr, number, score)
+	if improved {
+		if err := candidates.Store(Ip2Team(ipaddr), number, score, answer); err != nil {
+			logger.Error("candidate save", "error", err)
+		}
 	}
-	rankup, beFst := ranking.Append(ipaddr, number, score)
 	if rankup {
 		SendToNirvana(ipaddr, beFst)
 	}
+	return flag
+}
+
+// readAnswer decodes the gzipped candidate image for q in the request
+// body, either as text or, for application/json, as an AnswerRequest.
+func readAnswer(r *http.Request, q Question) (Map, error) {
+	defer r.Body.Close()
+	limit := maxBodySize()
 
-	resp := map[string]interface{}{
-		"wrong": wrong,
-		"score": score,
+	gzipr, err := gzip.NewReader(&limitReader{r: r.Body, n: limit})
+	if err != nil {
+		if e, ok := err.(*APIError); ok {
+			return nil, e
+		}
+		return nil, errBadEncoding.withDetail("gzip error")
 	}
-	if flag != "" {
-		resp["flag"] = flag
+	defer gzipr.Close()
+	body := &limitReader{r: gzipr, n: limit}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		tryMap, err := parseJsonInput(body)
+		if err != nil {
+			if e, ok := err.(*APIError); ok {
+				return nil, e
+			}
+			return nil, errBadEncoding.withDetail("json error")
+		}
+		width, height := q.Size()
+		if err := tryMap.checkSize(width, height); err != nil {
+			return nil, err
+		}
+		return tryMap, nil
 	}
-	c.JSON(http.StatusOK, resp)
+
+	width, height := q.Size()
+	return parseMapStream(body, width, height)
+}
+
+// maxBodySize is the largest request body, compressed or not, that may
+// hold an image of the largest question in any accepted encoding.
+func maxBodySize() int64 {
+	width, height := game.MaxSize()
+	return int64(height*(4*width+4) + 1024)
+}
+
+// limitReader reads up to n bytes from r and fails with errTooLarge
+// beyond that.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var probe [1]byte
+		if n, err := l.r.Read(probe[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
 }
 
 func getIpAddr(r *http.Request) string {
@@ -99,17 +241,20 @@ func getIpAddr(r *http.Request) string {
 	return tcpaddr.IP.String()
 }
 
-func parseJsonInput(r *http.Request) (Map, error) {
-	jd := json.NewDecoder(r.Body)
+func parseJsonInput(r io.Reader) (Map, error) {
+	jd := json.NewDecoder(r)
 
-	var req [][]int
+	var req AnswerRequest
 	err := jd.Decode(&req)
 	if err != nil {
 		return nil, err
 	}
+	return req.toMap(), nil
+}
 
+func (req AnswerRequest) toMap() Map {
 	rMap := make(Map, 0)
-	for _, reqLine := range req {
+	for _, reqLine := range req.Map {
 		rLine := make([]bool, 0)
 		for _, reqDot := range reqLine {
 			if reqDot == 0 {
@@ -120,37 +265,75 @@ func parseJsonInput(r *http.Request) (Map, error) {
 		}
 		rMap = append(rMap, rLine)
 	}
-	return rMap, nil
+	return rMap
 }
 
 type IntervalBreaker struct {
+	clock    Clock
 	duration time.Duration
 	memo     map[string]time.Time
 	mu       *sync.Mutex
 }
 
-func NewIntervalBreaker(d time.Duration) *IntervalBreaker {
+func NewIntervalBreaker(clock Clock, d time.Duration) *IntervalBreaker {
 	return &IntervalBreaker{
+		clock:    clock,
 		duration: d,
 		memo:     make(map[string]time.Time),
 		mu:       &sync.Mutex{},
 	}
 }
 
+// SetDuration changes the interval, the state of the teams is kept.
+func (i *IntervalBreaker) SetDuration(d time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.duration = d
+}
+
+// Next returns when team may send its next request.
+func (i *IntervalBreaker) Next(team string) time.Time {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	last, ok := i.memo[team]
+	if !ok {
+		return time.Time{}
+	}
+	return last.Add(i.duration)
+}
+
+// Snapshot returns when each team may be charged next, less one interval.
+func (i *IntervalBreaker) Snapshot() map[string]time.Time {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	m := make(map[string]time.Time, len(i.memo))
+	for k, v := range i.memo {
+		m[k] = v
+	}
+	return m
+}
+
 func (i *IntervalBreaker) Check(ipaddr string) bool {
-	now := time.Now()
+	return i.CheckN(ipaddr, 1)
+}
+
+// CheckN is Check for a request that counts as cost requests, the team
+// has to wait cost intervals before the next one.
+func (i *IntervalBreaker) CheckN(ipaddr string, cost float64) bool {
+	now := i.clock.Now()
 	team := Ip2Team(ipaddr)
 
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	if last, ok := i.memo[team]; ok {
-		i.memo[team] = now
-		if now.Sub(last) < i.duration {
-			return false
-		}
-		return true
-	} else {
-		i.memo[team] = now
-		return true
+	last, ok := i.memo[team]
+	i.memo[team] = now.Add(time.Duration((cost - 1) * float64(i.duration)))
+	if ok && now.Sub(last) < i.duration {
+		return false
 	}
+	return true
+}
+
+// Ready tells whether team may send a request now, without charging it.
+func (i *IntervalBreaker) Ready(team string) bool {
+	return !i.Next(team).After(i.clock.Now())
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTeamflag(t *testing.T) {
+	r, _ := testServer(t, QuestionConfig{Map: "01 10"})
+	ranking.List["a"] = RankingItem{IpAddress: "192.168.1.1", Name: "a", Score: []int{4}, TotalScore: 4}
+	ranking.List["b"] = RankingItem{IpAddress: "192.168.2.1", Name: "b", Score: []int{2}, TotalScore: 2}
+	for _, c := range []struct {
+		ip   string
+		code int
+	}{
+		{"10.0.0.1", http.StatusForbidden},
+		{"192.168.2.1", http.StatusForbidden},
+		{"192.168.1.7", http.StatusOK},
+	} {
+		w := serve(r, c.ip, "GET", "/teamflag.txt", "", nil)
+		if w.Code != c.code {
+			t.Errorf("%s: got %d %s, want %d", c.ip, w.Code, w.Body, c.code)
+		}
+		if c.code == http.StatusOK && w.Body.String() != getSLAFlag("192.168.1.1") {
+			t.Errorf("%s: got flag %q", c.ip, w.Body)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlagIssuer hands out attack flags watermarked with the receiving team,
+// so that a flag submitted by someone else can be traced back to its owner.
+type FlagIssuer struct {
+	Issued []IssuedFlag
+	secret []byte
+	mu     *sync.Mutex
+}
+
+type IssuedFlag struct {
+	Team     string
+	Question int
+	Flag     string
+	Time     time.Time
+}
+
+func NewFlagIssuer(secret string) *FlagIssuer {
+	return &FlagIssuer{
+		Issued: []IssuedFlag{},
+		secret: []byte(secret),
+		mu:     &sync.Mutex{},
+	}
+}
+
+// NewFlagIssuerFromFile is NewFlagIssuer with the records saved to path,
+// if there are any.
+func NewFlagIssuerFromFile(secret, path string) (*FlagIssuer, error) {
+	fi := NewFlagIssuer(secret)
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fi, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, fi); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fi, nil
+}
+
+// Issue returns the flag of question number for team and records it,
+// first tells whether team got it for the first time. Without a secret
+// the base flag is returned as is.
+func (fi *FlagIssuer) Issue(team string, number int, base string) (flag string, first bool) {
+	flag = fi.watermark(team, base)
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	for _, i := range fi.Issued {
+		if i.Team == team && i.Question == number {
+			return flag, false
+		}
+	}
+	fi.Issued = append(fi.Issued, IssuedFlag{
+		Team:     team,
+		Question: number,
+		Flag:     flag,
+		Time:     clock.Now(),
+	})
+	metricFlags.WithLabelValues(strconv.Itoa(number + 1)).Inc()
+	err := fi.Save("flags_backup.json")
+	if err != nil {
+		logger.Error("flag save", "error", err)
+	}
+	return flag, true
+}
+
+// Owner tells which team flag was originally issued to.
+func (fi *FlagIssuer) Owner(flag string, teams []string) (string, bool) {
+	fi.mu.Lock()
+	for _, i := range fi.Issued {
+		if i.Flag == flag {
+			fi.mu.Unlock()
+			return i.Team, true
+		}
+	}
+	fi.mu.Unlock()
+
+	base, ok := unwatermark(flag)
+	if !ok {
+		return "", false
+	}
+	for _, team := range teams {
+		if hmac.Equal([]byte(fi.watermark(team, base)), []byte(flag)) {
+			return team, true
+		}
+	}
+	return "", false
+}
+
+// List returns a copy of the flags issued so far.
+func (fi *FlagIssuer) List() []IssuedFlag {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return append([]IssuedFlag{}, fi.Issued...)
+}
+
+func (fi *FlagIssuer) Save(path string) error {
+	buf, err := json.Marshal(fi)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf)
+}
+
+// writeFileAtomic replaces path with buf, readable by the owner only, so
+// that a crash never leaves a truncated file behind.
+func writeFileAtomic(path string, buf []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// watermark turns "SECCON{base}" into "SECCON{base-<hmac(team)>}".
+func (fi *FlagIssuer) watermark(team, base string) string {
+	if len(fi.secret) == 0 {
+		return base
+	}
+	mac := hmac.New(sha256.New, fi.secret)
+	mac.Write([]byte(base))
+	mac.Write([]byte{0})
+	mac.Write([]byte(team))
+	sum := hex.EncodeToString(mac.Sum(nil))[:16]
+
+	open := strings.Index(base, "{")
+	close := strings.LastIndex(base, "}")
+	if open < 0 || close < open {
+		return base + "-" + sum
+	}
+	return base[:close] + "-" + sum + base[close:]
+}
+
+// unwatermark recovers the base flag from a watermarked one.
+func unwatermark(flag string) (string, bool) {
+	close := strings.LastIndex(flag, "}")
+	if close < 0 {
+		close = len(flag)
+	}
+	sep := strings.LastIndex(flag[:close], "-")
+	if sep < 0 {
+		return "", false
+	}
+	return flag[:sep] + flag[close:], true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFlagIssuerOwner(t *testing.T) {
+	fi := NewFlagIssuer("secret")
+	teams := []string{"scryptos", "urandom", "nw"}
+
+	a := fi.watermark("scryptos", "SECCON{123}")
+	b := fi.watermark("urandom", "SECCON{123}")
+	if a == b {
+		t.Fatal("flags of different teams must differ")
+	}
+	if a[:11] != "SECCON{123-" || a[len(a)-1] != '}' {
+		t.Errorf("unexpected flag format: %s", a)
+	}
+	if team, ok := fi.Owner(b, teams); !ok || team != "urandom" {
+		t.Errorf("owner is %q, want %q", team, "urandom")
+	}
+	if _, ok := fi.Owner("SECCON{123-0000000000000000}", teams); ok {
+		t.Error("forged flag must not have an owner")
+	}
+
+	if NewFlagIssuer("").watermark("nw", "SECCON{123}") != "SECCON{123}" {
+		t.Error("flag must be static without a secret")
+	}
+}
+
+func TestFlagIssuerRestart(t *testing.T) {
+	t.Chdir(t.TempDir())
+	fi := NewFlagIssuer("secret")
+	before := testutil.ToFloat64(metricFlags.WithLabelValues("1"))
+	a, first := fi.Issue("scryptos", 0, "SECCON{123}")
+	if !first {
+		t.Error("the first issuance must be first")
+	}
+	if _, first := fi.Issue("scryptos", 0, "SECCON{123}"); first {
+		t.Error("a repeat solve must not be first")
+	}
+	if n := testutil.ToFloat64(metricFlags.WithLabelValues("1")) - before; n != 1 {
+		t.Errorf("%v flags counted, want 1", n)
+	}
+	if st, err := os.Stat("flags_backup.json"); err != nil || st.Mode().Perm() != 0600 {
+		t.Errorf("unexpected backup: %v, %v", st, err)
+	}
+
+	fi, err := NewFlagIssuerFromFile("secret", "flags_backup.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi.Issue("urandom", 0, "SECCON{123}")
+	fi, _ = NewFlagIssuerFromFile("secret", "flags_backup.json")
+	if list := fi.List(); len(list) != 2 || list[0].Flag != a {
+		t.Errorf("records must survive a restart: %v", list)
+	}
+}